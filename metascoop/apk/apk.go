@@ -0,0 +1,208 @@
+package apk
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Attribute resource ids from android.R.attr
+const (
+	attrIcon             = 0x01010002
+	attrName             = 0x01010003
+	attrDebuggable       = 0x0101000f
+	attrMinSdkVersion    = 0x0101020c
+	attrVersionCode      = 0x0101021b
+	attrVersionName      = 0x0101021c
+	attrTargetSdkVersion = 0x01010270
+)
+
+// Info is what metascoop needs to know about an APK before publishing it
+type Info struct {
+	PackageName      string
+	VersionCode      int
+	VersionName      string
+	MinSdkVersion    int
+	TargetSdkVersion int
+	Permissions      []string
+	NativeCode       []string
+	Debuggable       bool
+}
+
+// APK is an opened APK with its decoded manifest and resource table
+type APK struct {
+	zip      *zip.ReadCloser
+	Manifest *Element
+	// Table is nil when the APK has no resources.arsc
+	Table *Table
+}
+
+// Open opens the APK at path and decodes its manifest and resource table
+func Open(path string) (a *APK, err error) {
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		return
+	}
+	a = &APK{zip: z}
+	defer func() {
+		if err != nil {
+			_ = z.Close()
+			a = nil
+		}
+	}()
+
+	b, err := a.ReadFile("AndroidManifest.xml")
+	if err != nil {
+		err = fmt.Errorf("reading AndroidManifest.xml: %w", err)
+		return
+	}
+	if a.Manifest, err = ParseXML(b); err != nil {
+		err = fmt.Errorf("decoding AndroidManifest.xml: %w", err)
+		return
+	}
+	if a.Manifest.Name != "manifest" {
+		err = fmt.Errorf("%w: root element is %q instead of manifest", ErrMalformed, a.Manifest.Name)
+		return
+	}
+
+	b, err = a.ReadFile("resources.arsc")
+	if err != nil {
+		// Resources are optional, references just stay unresolved
+		err = nil
+		return
+	}
+	if a.Table, err = ParseTable(b); err != nil {
+		err = fmt.Errorf("decoding resources.arsc: %w", err)
+	}
+	return
+}
+
+func (a *APK) Close() error {
+	return a.zip.Close()
+}
+
+// Files returns the names of every entry of the APK
+func (a *APK) Files() (names []string) {
+	for _, f := range a.zip.File {
+		names = append(names, f.Name)
+	}
+	return
+}
+
+// ReadFile returns the content of the named entry
+func (a *APK) ReadFile(name string) (b []byte, err error) {
+	rc, err := a.zip.Open(name)
+	if err != nil {
+		return
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// ResolveString returns the string value of attr, following resource references for locale
+func (a *APK) ResolveString(attr Attr, locale string) string {
+	v := attr.Value
+	if v.Type == TypeReference && a.Table != nil {
+		if resolved, ok := a.Table.Resolve(v, locale); ok {
+			if resolved.Type == TypeString {
+				return a.Table.String(resolved)
+			}
+			return Attr{Value: resolved}.String()
+		}
+	}
+	return attr.String()
+}
+
+func (a *APK) resolveInt(attr Attr) (i int, err error) {
+	v := attr.Value
+	if v.Type == TypeReference && a.Table != nil {
+		v, _ = a.Table.Resolve(v, "")
+	}
+	switch v.Type {
+	case TypeIntDec, TypeIntHex, TypeBoolean:
+		return int(int32(v.Data)), nil
+	case TypeString:
+		return strconv.Atoi(strings.TrimSpace(attr.Raw))
+	}
+	return 0, fmt.Errorf("%w: %q is not an integer", ErrMalformed, attr.Name)
+}
+
+// Info extracts the package information from the manifest
+func (a *APK) Info() (info *Info, err error) {
+	m := a.Manifest
+	info = &Info{}
+
+	pkg, ok := m.Attr("", "package", 0)
+	if !ok || pkg.String() == "" {
+		err = fmt.Errorf("%w: manifest has no package", ErrMalformed)
+		return
+	}
+	info.PackageName = pkg.String()
+
+	vc, ok := m.Attr(AndroidNS, "versionCode", attrVersionCode)
+	if !ok {
+		err = fmt.Errorf("%w: manifest has no versionCode", ErrMalformed)
+		return
+	}
+	if info.VersionCode, err = a.resolveInt(vc); err != nil {
+		return
+	}
+	if vn, ok := m.Attr(AndroidNS, "versionName", attrVersionName); ok {
+		info.VersionName = a.ResolveString(vn, "")
+	}
+
+	for _, sdk := range m.Find("uses-sdk") {
+		if v, ok := sdk.Attr(AndroidNS, "minSdkVersion", attrMinSdkVersion); ok {
+			info.MinSdkVersion, _ = a.resolveInt(v)
+		}
+		if v, ok := sdk.Attr(AndroidNS, "targetSdkVersion", attrTargetSdkVersion); ok {
+			info.TargetSdkVersion, _ = a.resolveInt(v)
+		}
+	}
+	// Defaults as defined by the platform
+	if info.MinSdkVersion == 0 {
+		info.MinSdkVersion = 1
+	}
+	if info.TargetSdkVersion == 0 {
+		info.TargetSdkVersion = info.MinSdkVersion
+	}
+
+	for _, p := range append(m.Find("uses-permission"), m.Find("uses-permission-sdk-23")...) {
+		if v, ok := p.Attr(AndroidNS, "name", attrName); ok {
+			info.Permissions = append(info.Permissions, a.ResolveString(v, ""))
+		}
+	}
+
+	for _, app := range m.Find("application") {
+		if v, ok := app.Attr(AndroidNS, "debuggable", attrDebuggable); ok {
+			d, _ := a.resolveInt(v)
+			info.Debuggable = d != 0
+		}
+	}
+
+	for _, name := range a.Files() {
+		if !strings.HasPrefix(name, "lib/") || !strings.HasSuffix(name, ".so") {
+			continue
+		}
+		parts := strings.Split(name, "/")
+		if len(parts) >= 3 && !slices.Contains(info.NativeCode, parts[1]) {
+			info.NativeCode = append(info.NativeCode, parts[1])
+		}
+	}
+	slices.Sort(info.NativeCode)
+
+	return
+}
+
+// ReadInfo opens the APK at path and returns its package information
+func ReadInfo(path string) (info *Info, err error) {
+	a, err := Open(path)
+	if err != nil {
+		return
+	}
+	defer a.Close()
+	return a.Info()
+}
@@ -0,0 +1,104 @@
+package apk
+
+import (
+	"slices"
+	"testing"
+)
+
+// testdata/app.apk has a manifest referencing its resources, whose package has a type id offset of 1:
+// mipmap/ic_launcher is 0x7f020000 with mdpi and xxxhdpi bitmaps, string/app_name 0x7f030000
+// in English and German and string/version_name 0x7f030001
+
+func TestReadInfo(t *testing.T) {
+	info, err := ReadInfo("testdata/app.apk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Info{
+		PackageName:      "org.example.fixture",
+		VersionCode:      42,
+		VersionName:      "1.2.3",
+		MinSdkVersion:    21,
+		TargetSdkVersion: 34,
+		Permissions:      []string{"android.permission.INTERNET", "android.permission.CAMERA"},
+		NativeCode:       []string{"arm64-v8a"},
+	}
+	if info.PackageName != want.PackageName || info.VersionCode != want.VersionCode || info.VersionName != want.VersionName ||
+		info.MinSdkVersion != want.MinSdkVersion || info.TargetSdkVersion != want.TargetSdkVersion ||
+		!slices.Equal(info.Permissions, want.Permissions) || !slices.Equal(info.NativeCode, want.NativeCode) || info.Debuggable {
+		t.Errorf("got %+v, want %+v", *info, want)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	a, err := Open("testdata/app.apk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	apps := a.Manifest.Find("application")
+	if len(apps) != 1 {
+		t.Fatalf("got %d application elements", len(apps))
+	}
+	label, ok := apps[0].Attr(AndroidNS, "label", 0x01010001)
+	if !ok || label.Value.Type != TypeReference || label.String() != "@0x7f030000" {
+		t.Fatalf("got label %+v", label)
+	}
+	for locale, want := range map[string]string{"": "Fixture", "de": "Fixtur", "fr": "Fixture"} {
+		if got := a.ResolveString(label, locale); got != want {
+			t.Errorf("label in %q: got %q, want %q", locale, got, want)
+		}
+	}
+	// Attributes are also found by name when the resource id is unknown
+	if sdk := a.Manifest.Find("uses-sdk"); len(sdk) != 1 {
+		t.Errorf("got %d uses-sdk elements", len(sdk))
+	} else if v, ok := sdk[0].Attr(AndroidNS, "minSdkVersion", 0); !ok || v.String() != "21" {
+		t.Errorf("got minSdkVersion %+v", v)
+	}
+}
+
+func TestTable(t *testing.T) {
+	a, err := Open("testdata/app.apk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	for id, want := range map[uint32][]string{
+		0x7f020000: {"mipmap/ic_launcher", "mipmap/ic_launcher"},
+		0x7f030000: {"string/app_name", "string/app_name"},
+		0x7f030001: {"string/version_name"},
+	} {
+		entries := a.Table.Entries(id)
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Type+"/"+e.Key)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("0x%08x: got %v, want %v", id, got, want)
+		}
+	}
+
+	icons := a.Table.Entries(0x7f020000)
+	if len(icons) != 2 || icons[0].Config.Density != 160 || icons[1].Config.Density != 640 {
+		t.Fatalf("got icons %+v", icons)
+	}
+	if got := a.Table.String(icons[1].Value); got != "res/mipmap-xxxhdpi-v4/ic_launcher.png" {
+		t.Errorf("got icon file %q", got)
+	}
+	if locale := a.Table.Entries(0x7f030000)[1].Config.Locale(); locale != "de" {
+		t.Errorf("got locale %q", locale)
+	}
+}
+
+func TestIcon(t *testing.T) {
+	img, err := ReadIcon("testdata/app.apk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The densest bitmap is picked
+	if size := img.Bounds().Dx(); size != 192 {
+		t.Errorf("got a %dpx icon, want the 192px one", size)
+	}
+}
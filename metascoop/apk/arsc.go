@@ -0,0 +1,247 @@
+package apk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	entryFlagComplex = 0x0001
+	entryFlagCompact = 0x0008
+
+	typeFlagSparse   = 0x01
+	typeFlagOffset16 = 0x02
+)
+
+// Config is the subset of ResTable_config used to pick between entries
+type Config struct {
+	Language string
+	Country  string
+	Density  uint16
+}
+
+// Locale returns the config locale as "ll" or "ll-CC", or "" for the default
+func (c Config) Locale() string {
+	if c.Country == "" {
+		return c.Language
+	}
+	return c.Language + "-" + c.Country
+}
+
+// Entry is one configuration-specific value of a resource
+type Entry struct {
+	Config Config
+	Type   string
+	Key    string
+	Value  Value
+	// Complex entries are bags (styles, arrays...) and have no single value
+	Complex bool
+}
+
+// Table is a decoded resources.arsc
+type Table struct {
+	strings stringPool
+	entries map[uint32][]Entry
+}
+
+// ParseTable decodes a compiled resources.arsc
+func ParseTable(b []byte) (t *Table, err error) {
+	root, err := readChunk(b, 0)
+	if err != nil {
+		return
+	}
+	if root.typ != chunkTable {
+		err = fmt.Errorf("%w: not a resource table", ErrMalformed)
+		return
+	}
+	t = &Table{entries: make(map[uint32][]Entry)}
+	err = eachChunk(root.body(), func(c chunk) (err error) {
+		switch c.typ {
+		case chunkStringPool:
+			t.strings, err = parseStringPool(c)
+		case chunkTablePackage:
+			err = t.parsePackage(c)
+		}
+		return
+	})
+	return
+}
+
+func (t *Table) parsePackage(c chunk) (err error) {
+	b := c.data
+	if c.headerSize < 284 {
+		return fmt.Errorf("%w: truncated package header", ErrMalformed)
+	}
+	id := binary.LittleEndian.Uint32(b[8:])
+	typeStringsOff := int(binary.LittleEndian.Uint32(b[268:]))
+	keyStringsOff := int(binary.LittleEndian.Uint32(b[276:]))
+	var typeIDOffset uint32
+	if c.headerSize >= 288 {
+		typeIDOffset = binary.LittleEndian.Uint32(b[284:])
+	}
+
+	var typeStrings, keyStrings stringPool
+	if typeStringsOff > 0 {
+		var sc chunk
+		if sc, err = readChunk(b, typeStringsOff); err != nil {
+			return
+		}
+		if typeStrings, err = parseStringPool(sc); err != nil {
+			return
+		}
+	}
+	if keyStringsOff > 0 {
+		var sc chunk
+		if sc, err = readChunk(b, keyStringsOff); err != nil {
+			return
+		}
+		if keyStrings, err = parseStringPool(sc); err != nil {
+			return
+		}
+	}
+
+	return eachChunk(c.body(), func(c chunk) error {
+		if c.typ != chunkTableType {
+			return nil
+		}
+		return t.parseType(c, id, typeIDOffset, typeStrings, keyStrings)
+	})
+}
+
+func (t *Table) parseType(c chunk, pkgID, typeIDOffset uint32, typeStrings, keyStrings stringPool) error {
+	b := c.data
+	if c.headerSize < 24 {
+		return fmt.Errorf("%w: truncated type header", ErrMalformed)
+	}
+	typeID := uint32(b[8])
+	flags := b[9]
+	entryCount := int(binary.LittleEndian.Uint32(b[12:]))
+	entriesStart := int(binary.LittleEndian.Uint32(b[16:]))
+	config := parseConfig(b[20:c.headerSize])
+	// Type ids of shared libraries start after the offset, their names don't
+	typeName := typeStrings.get(typeID - 1 - typeIDOffset)
+
+	offsets := make(map[int]int, entryCount)
+	idx := b[c.headerSize:]
+	switch {
+	case flags&typeFlagSparse != 0:
+		for i := 0; i < entryCount && i*4+4 <= len(idx); i++ {
+			offsets[int(binary.LittleEndian.Uint16(idx[i*4:]))] = int(binary.LittleEndian.Uint16(idx[i*4+2:])) * 4
+		}
+	case flags&typeFlagOffset16 != 0:
+		for i := 0; i < entryCount && i*2+2 <= len(idx); i++ {
+			if off := binary.LittleEndian.Uint16(idx[i*2:]); off != 0xffff {
+				offsets[i] = int(off) * 4
+			}
+		}
+	default:
+		for i := 0; i < entryCount && i*4+4 <= len(idx); i++ {
+			if off := binary.LittleEndian.Uint32(idx[i*4:]); off != noIndex {
+				offsets[i] = int(off)
+			}
+		}
+	}
+
+	for i, off := range offsets {
+		e := b[min(entriesStart+off, len(b)):]
+		if len(e) < 8 {
+			return fmt.Errorf("%w: entry %d of type %q out of bounds", ErrMalformed, i, typeName)
+		}
+		size := int(binary.LittleEndian.Uint16(e))
+		entryFlags := binary.LittleEndian.Uint16(e[2:])
+		entry := Entry{Config: config, Type: typeName}
+		switch {
+		case entryFlags&entryFlagCompact != 0:
+			entry.Key = keyStrings.get(uint32(size))
+			entry.Value = Value{Type: uint8(entryFlags >> 8), Data: binary.LittleEndian.Uint32(e[4:])}
+		case entryFlags&entryFlagComplex != 0:
+			entry.Key = keyStrings.get(binary.LittleEndian.Uint32(e[4:]))
+			entry.Complex = true
+		default:
+			entry.Key = keyStrings.get(binary.LittleEndian.Uint32(e[4:]))
+			if size+8 > len(e) {
+				return fmt.Errorf("%w: value of %q out of bounds", ErrMalformed, entry.Key)
+			}
+			entry.Value = readValue(e[size:])
+		}
+		id := pkgID<<24 | typeID<<16 | uint32(i)
+		t.entries[id] = append(t.entries[id], entry)
+	}
+	return nil
+}
+
+func parseConfig(b []byte) (c Config) {
+	if len(b) < 16 {
+		return
+	}
+	c.Language = unpackLocale(b[8:10])
+	c.Country = strings.ToUpper(unpackLocale(b[10:12]))
+	c.Density = binary.LittleEndian.Uint16(b[14:])
+	return
+}
+
+func unpackLocale(b []byte) string {
+	if b[0] == 0 {
+		return ""
+	}
+	if b[0]&0x80 == 0 {
+		return string(b)
+	}
+	// Three letters packed in two bytes
+	return string([]byte{
+		'a' + b[1]&0x1f,
+		'a' + ((b[1]&0xe0)>>5 | (b[0]&0x03)<<3),
+		'a' + (b[0]&0x7c)>>2,
+	})
+}
+
+// Entries returns every configuration of the resource id
+func (t *Table) Entries(id uint32) []Entry {
+	return t.entries[id]
+}
+
+// String returns the global pool string referenced by a TypeString value
+func (t *Table) String(v Value) string {
+	if v.Type != TypeString {
+		return ""
+	}
+	return t.strings.get(v.Data)
+}
+
+// Resolve follows references from v and returns the value for locale,
+// falling back to the default configuration
+func (t *Table) Resolve(v Value, locale string) (Value, bool) {
+	for depth := 0; v.Type == TypeReference && depth < 16; depth++ {
+		entries := t.entries[v.Data]
+		if len(entries) == 0 {
+			return v, false
+		}
+		best, def, first := -1, -1, -1
+		for i, e := range entries {
+			if e.Complex {
+				continue
+			}
+			l := e.Config.Locale()
+			switch {
+			case locale != "" && l == locale && best < 0:
+				best = i
+			case l == "" && def < 0:
+				def = i
+			case first < 0:
+				first = i
+			}
+		}
+		if best < 0 {
+			best = def
+		}
+		if best < 0 {
+			best = first
+		}
+		if best < 0 {
+			return v, false
+		}
+		v = entries[best].Value
+	}
+	return v, v.Type != TypeReference
+}
@@ -0,0 +1,166 @@
+package apk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+const (
+	chunkStringPool   = 0x0001
+	chunkTable        = 0x0002
+	chunkXML          = 0x0003
+	chunkXMLStartNS   = 0x0100
+	chunkXMLEndNS     = 0x0101
+	chunkXMLStartElem = 0x0102
+	chunkXMLEndElem   = 0x0103
+	chunkXMLResMap    = 0x0180
+	chunkTablePackage = 0x0200
+	chunkTableType    = 0x0201
+
+	noIndex = 0xffffffff
+)
+
+var ErrMalformed = errors.New("malformed binary resource")
+
+type chunk struct {
+	typ        uint16
+	headerSize int
+	data       []byte
+}
+
+func (c chunk) body() []byte { return c.data[c.headerSize:] }
+
+func readChunk(b []byte, off int) (c chunk, err error) {
+	if off+8 > len(b) {
+		err = fmt.Errorf("%w: truncated chunk header at %d", ErrMalformed, off)
+		return
+	}
+	c.typ = binary.LittleEndian.Uint16(b[off:])
+	c.headerSize = int(binary.LittleEndian.Uint16(b[off+2:]))
+	size := int(binary.LittleEndian.Uint32(b[off+4:]))
+	if size < 8 || c.headerSize < 8 || c.headerSize > size || off+size > len(b) {
+		err = fmt.Errorf("%w: invalid chunk 0x%04x of size %d at %d", ErrMalformed, c.typ, size, off)
+		return
+	}
+	c.data = b[off : off+size]
+	return
+}
+
+// eachChunk iterates over the chunks contained in b
+func eachChunk(b []byte, fn func(c chunk) error) (err error) {
+	for off := 0; off < len(b); {
+		var c chunk
+		c, err = readChunk(b, off)
+		if err != nil {
+			return
+		}
+		if err = fn(c); err != nil {
+			return
+		}
+		off += len(c.data)
+	}
+	return
+}
+
+type stringPool []string
+
+func (p stringPool) get(i uint32) string {
+	if i == noIndex || int(i) >= len(p) {
+		return ""
+	}
+	return p[i]
+}
+
+func parseStringPool(c chunk) (pool stringPool, err error) {
+	if c.typ != chunkStringPool || c.headerSize < 28 {
+		err = fmt.Errorf("%w: expected string pool", ErrMalformed)
+		return
+	}
+	b := c.data
+	count := int(binary.LittleEndian.Uint32(b[8:]))
+	flags := binary.LittleEndian.Uint32(b[16:])
+	stringsStart := int(binary.LittleEndian.Uint32(b[20:]))
+	isUTF8 := flags&0x100 != 0
+
+	if c.headerSize+count*4 > len(b) || stringsStart > len(b) {
+		err = fmt.Errorf("%w: string pool out of bounds", ErrMalformed)
+		return
+	}
+
+	pool = make(stringPool, count)
+	for i := 0; i < count; i++ {
+		off := stringsStart + int(binary.LittleEndian.Uint32(b[c.headerSize+i*4:]))
+		if off >= len(b) {
+			err = fmt.Errorf("%w: string %d out of bounds", ErrMalformed, i)
+			return
+		}
+		if isUTF8 {
+			pool[i], err = decodeUTF8(b[off:])
+		} else {
+			pool[i], err = decodeUTF16(b[off:])
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func decodeUTF8(b []byte) (s string, err error) {
+	// The utf-16 length comes first and is skipped
+	_, n := utf8Length(b)
+	if n == 0 {
+		err = fmt.Errorf("%w: truncated string", ErrMalformed)
+		return
+	}
+	b = b[n:]
+	length, n := utf8Length(b)
+	if n == 0 || n+length > len(b) {
+		err = fmt.Errorf("%w: truncated string", ErrMalformed)
+		return
+	}
+	s = string(b[n : n+length])
+	return
+}
+
+func utf8Length(b []byte) (length int, n int) {
+	if len(b) < 1 {
+		return
+	}
+	if b[0]&0x80 == 0 {
+		return int(b[0]), 1
+	}
+	if len(b) < 2 {
+		return
+	}
+	return int(b[0]&0x7f)<<8 | int(b[1]), 2
+}
+
+func decodeUTF16(b []byte) (s string, err error) {
+	if len(b) < 2 {
+		err = fmt.Errorf("%w: truncated string", ErrMalformed)
+		return
+	}
+	length := int(binary.LittleEndian.Uint16(b))
+	b = b[2:]
+	if length&0x8000 != 0 {
+		if len(b) < 2 {
+			err = fmt.Errorf("%w: truncated string", ErrMalformed)
+			return
+		}
+		length = (length&0x7fff)<<16 | int(binary.LittleEndian.Uint16(b))
+		b = b[2:]
+	}
+	if length*2 > len(b) {
+		err = fmt.Errorf("%w: truncated string", ErrMalformed)
+		return
+	}
+	u := make([]uint16, length)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	s = string(utf16.Decode(u))
+	return
+}
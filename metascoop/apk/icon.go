@@ -0,0 +1,229 @@
+package apk
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"path"
+	"slices"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Color value types of a Res_value, their data is always 0xAARRGGBB
+const (
+	TypeColorARGB8 = 0x1c
+	TypeColorRGB8  = 0x1d
+	TypeColorARGB4 = 0x1e
+	TypeColorRGB4  = 0x1f
+)
+
+const attrDrawable = 0x01010199
+
+// adaptiveSize is the size of the 108dp layers of an adaptive icon at xxxhdpi
+const adaptiveSize = 432
+
+// IconSize is the size vector launcher icons are rendered at
+const IconSize = 512
+
+// Densities of the configurations of density independent resources
+const (
+	densityAny  = 0xfffe
+	densityNone = 0xffff
+)
+
+var ErrUnsupported = errors.New("unsupported drawable")
+
+func colorOf(v Value) (c color.NRGBA, ok bool) {
+	switch v.Type {
+	case TypeColorARGB8, TypeColorARGB4:
+		c.A = uint8(v.Data >> 24)
+	case TypeColorRGB8, TypeColorRGB4:
+		c.A = 0xff
+	default:
+		return
+	}
+	c.R, c.G, c.B = uint8(v.Data>>16), uint8(v.Data>>8), uint8(v.Data)
+	return c, true
+}
+
+// Icon returns the launcher icon: the densest bitmap of the android:icon resource,
+// or its adaptive icon or vector drawable rendered at IconSize
+func (a *APK) Icon() (img image.Image, err error) {
+	var attr Attr
+	ok := false
+	for _, app := range a.Manifest.Find("application") {
+		if attr, ok = app.Attr(AndroidNS, "icon", attrIcon); ok {
+			break
+		}
+	}
+	if !ok {
+		err = fmt.Errorf("%w: the application has no icon", ErrUnsupported)
+		return
+	}
+	return a.drawable(attr.Value, IconSize)
+}
+
+func (a *APK) decodeImage(name string) (img image.Image, err error) {
+	b, err := a.ReadFile(name)
+	if err != nil {
+		return
+	}
+	img, _, err = image.Decode(bytes.NewReader(b))
+	return
+}
+
+// densityRank orders the densities of bitmaps, the default one being mdpi and
+// the density independent ones the last resort
+func densityRank(d uint16) int {
+	switch d {
+	case 0:
+		return 160
+	case densityAny, densityNone:
+		return 0
+	}
+	return int(d)
+}
+
+// drawable returns the image of a drawable value, a color or the resource of one.
+// XML drawables are rendered at size when the resource has no bitmap.
+func (a *APK) drawable(v Value, size int) (img image.Image, err error) {
+	if c, ok := colorOf(v); ok {
+		return image.NewUniform(c), nil
+	}
+	if v.Type != TypeReference || a.Table == nil {
+		err = fmt.Errorf("%w: value of type 0x%02x", ErrUnsupported, v.Type)
+		return
+	}
+
+	var best image.Image
+	bestRank := -1
+	var documents []*Element
+	name := fmt.Sprintf("resource 0x%08x", v.Data)
+	for _, e := range a.Table.Entries(v.Data) {
+		if e.Complex {
+			continue
+		}
+		name = e.Type + "/" + e.Key
+		ev, ok := e.Value, true
+		if ev.Type == TypeReference {
+			if ev, ok = a.Table.Resolve(ev, ""); !ok {
+				continue
+			}
+		}
+		if c, ok := colorOf(ev); ok {
+			return image.NewUniform(c), nil
+		}
+		if ev.Type != TypeString {
+			continue
+		}
+		file := a.Table.String(ev)
+		switch strings.ToLower(path.Ext(file)) {
+		case ".png", ".webp", ".jpg":
+			img, derr := a.decodeImage(file)
+			if derr != nil {
+				continue
+			}
+			rank := densityRank(e.Config.Density)
+			if rank > bestRank || rank == bestRank && img.Bounds().Dx() > best.Bounds().Dx() {
+				best, bestRank = img, rank
+			}
+		case ".xml":
+			b, rerr := a.ReadFile(file)
+			if rerr != nil {
+				continue
+			}
+			if root, perr := ParseXML(b); perr == nil {
+				documents = append(documents, root)
+			}
+		}
+	}
+	if best != nil {
+		return best, nil
+	}
+
+	// Launchers prefer the adaptive icon to the vector one
+	slices.SortStableFunc(documents, func(x, y *Element) int {
+		return cmp.Compare(xmlDrawableRank(x.Name), xmlDrawableRank(y.Name))
+	})
+	for _, root := range documents {
+		switch root.Name {
+		case "adaptive-icon":
+			return a.adaptiveIcon(root)
+		case "vector":
+			return a.vectorDrawable(root, size)
+		}
+	}
+	kinds := make([]string, len(documents))
+	for i, root := range documents {
+		kinds[i] = "<" + root.Name + ">"
+	}
+	if len(kinds) == 0 {
+		err = fmt.Errorf("%w: no raster launcher icon in %s", ErrUnsupported, name)
+	} else {
+		err = fmt.Errorf("%w: no raster launcher icon in %s, only %s drawables", ErrUnsupported, name, strings.Join(kinds, ", "))
+	}
+	return
+}
+
+func xmlDrawableRank(name string) int {
+	switch name {
+	case "adaptive-icon":
+		return 0
+	case "vector":
+		return 1
+	}
+	return 2
+}
+
+// adaptiveIcon draws the background and foreground layers of an adaptive icon
+func (a *APK) adaptiveIcon(root *Element) (img image.Image, err error) {
+	canvas := image.NewNRGBA(image.Rect(0, 0, adaptiveSize, adaptiveSize))
+	for _, layer := range []string{"background", "foreground"} {
+		var v Attr
+		ok := false
+		for _, e := range root.Children {
+			if e.Name == layer {
+				v, ok = e.Attr(AndroidNS, "drawable", attrDrawable)
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		l, lerr := a.drawable(v.Value, adaptiveSize)
+		if lerr != nil {
+			// The icon stays recognizable on a transparent background
+			if layer == "background" {
+				continue
+			}
+			return nil, fmt.Errorf("adaptive icon %s: %w", layer, lerr)
+		}
+		if u, ok := l.(*image.Uniform); ok {
+			draw.Draw(canvas, canvas.Bounds(), u, image.Point{}, draw.Over)
+			continue
+		}
+		draw.CatmullRom.Scale(canvas, canvas.Bounds(), l, l.Bounds(), draw.Over, nil)
+	}
+
+	// Launchers show the inner 72dp of the 108dp layers
+	inset := adaptiveSize / 6
+	return canvas.SubImage(image.Rect(inset, inset, adaptiveSize-inset, adaptiveSize-inset)), nil
+}
+
+// ReadIcon opens the APK at path and returns its launcher icon
+func ReadIcon(path string) (img image.Image, err error) {
+	a, err := Open(path)
+	if err != nil {
+		return
+	}
+	defer a.Close()
+	return a.Icon()
+}
@@ -0,0 +1,187 @@
+package apk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const AndroidNS = "http://schemas.android.com/apk/res/android"
+
+// Value types of a Res_value
+const (
+	TypeNull      = 0x00
+	TypeReference = 0x01
+	TypeAttribute = 0x02
+	TypeString    = 0x03
+	TypeFloat     = 0x04
+	TypeDimension = 0x05
+	TypeIntDec    = 0x10
+	TypeIntHex    = 0x11
+	TypeBoolean   = 0x12
+)
+
+// Value is a typed resource value
+type Value struct {
+	Type uint8
+	Data uint32
+}
+
+func readValue(b []byte) Value {
+	return Value{Type: b[3], Data: binary.LittleEndian.Uint32(b[4:])}
+}
+
+type Attr struct {
+	Namespace string
+	Name      string
+	// ResID is the attribute id from the resource map, 0 when unknown
+	ResID uint32
+	// Raw is the original string value, if any
+	Raw   string
+	Value Value
+}
+
+// String returns the value as written in the manifest, without resolving references
+func (a Attr) String() string {
+	if a.Raw != "" {
+		return a.Raw
+	}
+	switch a.Value.Type {
+	case TypeReference:
+		return fmt.Sprintf("@0x%08x", a.Value.Data)
+	case TypeBoolean:
+		return strconv.FormatBool(a.Value.Data != 0)
+	case TypeIntHex:
+		return fmt.Sprintf("0x%x", a.Value.Data)
+	case TypeFloat:
+		return strconv.FormatFloat(float64(math.Float32frombits(a.Value.Data)), 'f', -1, 32)
+	}
+	return strconv.FormatInt(int64(int32(a.Value.Data)), 10)
+}
+
+// Element is a node of a decoded binary XML document
+type Element struct {
+	Namespace string
+	Name      string
+	Attrs     []Attr
+	Children  []*Element
+}
+
+// Attr returns the attribute matching either the resource id or the name in ns
+func (e *Element) Attr(ns, name string, resID uint32) (Attr, bool) {
+	for _, a := range e.Attrs {
+		if resID != 0 && a.ResID == resID {
+			return a, true
+		}
+	}
+	for _, a := range e.Attrs {
+		if a.Namespace == ns && a.Name == name {
+			return a, true
+		}
+	}
+	return Attr{}, false
+}
+
+// Find returns all descendants with the given name
+func (e *Element) Find(name string) (elems []*Element) {
+	for _, c := range e.Children {
+		if c.Name == name {
+			elems = append(elems, c)
+		}
+		elems = append(elems, c.Find(name)...)
+	}
+	return
+}
+
+// ParseXML decodes a compiled Android XML document and returns its root element
+func ParseXML(b []byte) (root *Element, err error) {
+	doc, err := readChunk(b, 0)
+	if err != nil {
+		return
+	}
+	if doc.typ != chunkXML {
+		err = fmt.Errorf("%w: not a binary XML document", ErrMalformed)
+		return
+	}
+
+	var pool stringPool
+	var resMap []uint32
+	var stack []*Element
+
+	err = eachChunk(doc.body(), func(c chunk) (err error) {
+		switch c.typ {
+		case chunkStringPool:
+			pool, err = parseStringPool(c)
+		case chunkXMLResMap:
+			body := c.body()
+			resMap = make([]uint32, len(body)/4)
+			for i := range resMap {
+				resMap[i] = binary.LittleEndian.Uint32(body[i*4:])
+			}
+		case chunkXMLStartElem:
+			var e *Element
+			e, err = parseStartElement(c, pool, resMap)
+			if err != nil {
+				return
+			}
+			if len(stack) == 0 {
+				if root == nil {
+					root = e
+				}
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, e)
+			}
+			stack = append(stack, e)
+		case chunkXMLEndElem:
+			if len(stack) == 0 {
+				return fmt.Errorf("%w: unbalanced end element", ErrMalformed)
+			}
+			stack = stack[:len(stack)-1]
+		}
+		return
+	})
+	if err == nil && root == nil {
+		err = fmt.Errorf("%w: empty XML document", ErrMalformed)
+	}
+	return
+}
+
+func parseStartElement(c chunk, pool stringPool, resMap []uint32) (e *Element, err error) {
+	ext := c.body()
+	if len(ext) < 20 {
+		err = fmt.Errorf("%w: truncated start element", ErrMalformed)
+		return
+	}
+	e = &Element{
+		Namespace: pool.get(binary.LittleEndian.Uint32(ext[0:])),
+		Name:      pool.get(binary.LittleEndian.Uint32(ext[4:])),
+	}
+	attrStart := int(binary.LittleEndian.Uint16(ext[8:]))
+	attrSize := int(binary.LittleEndian.Uint16(ext[10:]))
+	attrCount := int(binary.LittleEndian.Uint16(ext[12:]))
+	if attrSize < 20 || attrStart+attrCount*attrSize > len(ext) {
+		err = fmt.Errorf("%w: attributes of %q out of bounds", ErrMalformed, e.Name)
+		return
+	}
+
+	for i := 0; i < attrCount; i++ {
+		b := ext[attrStart+i*attrSize:]
+		nameIdx := binary.LittleEndian.Uint32(b[4:])
+		a := Attr{
+			Namespace: pool.get(binary.LittleEndian.Uint32(b[0:])),
+			Name:      pool.get(nameIdx),
+			Raw:       pool.get(binary.LittleEndian.Uint32(b[8:])),
+			Value:     readValue(b[12:]),
+		}
+		if int(nameIdx) < len(resMap) {
+			a.ResID = resMap[nameIdx]
+		}
+		if a.Value.Type == TypeString && a.Raw == "" {
+			a.Raw = pool.get(a.Value.Data)
+		}
+		e.Attrs = append(e.Attrs, a)
+	}
+	return
+}
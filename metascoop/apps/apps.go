@@ -4,37 +4,60 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
 type AppFile struct {
-	BuildToolsVersion string              `yaml:"build_tools_version"`
-	Apps              map[string]*AppInfo `yaml:"apps"`
+	// Keep is the retention policy of the apps without their own
+	Keep *KeepPolicy         `yaml:"keep"`
+	Apps map[string]*AppInfo `yaml:"apps"`
 }
 
 func (a *AppFile) Apks() map[string]*AppInfo { return a.Apps }
 
 type AppInfo struct {
-	GitURL  string `yaml:"git"`
-	Summary string `yaml:"summary"`
-
-	AuthorName string `yaml:"author"`
-	repoAuthor string
-
-	FriendlyName string `yaml:"name"`
+	GitURL string `yaml:"git"`
+	// Forge is the kind of forge hosting the repository (github, gitlab or gitea), detected from the host when empty
+	Forge string `yaml:"forge"`
+	// Summary, FriendlyName and Description are either a string for en-US or a map of locales
+	Summary Localized `yaml:"summary"`
+
+	// PackageName is the expected package of downloaded APKs, it may contain glob patterns
+	PackageName string `yaml:"package_name"`
+	// MatchTag rejects the APKs whose versionName isn't their release tag without its "v"
+	MatchTag bool `yaml:"match_tag"`
+	// AllowedSigners are the SHA-256 fingerprints of the certificates allowed to sign the APKs
+	AllowedSigners []string `yaml:"allowed_signers"`
+	// Releases selects the releases to ingest, prereleases only by default
+	Releases ReleaseRules `yaml:"releases"`
+	// Assets selects the APKs of each release
+	Assets AssetRules `yaml:"assets"`
+	// Screenshots selects the screenshots of the repository
+	Screenshots ScreenshotRules `yaml:"screenshots"`
+	// Changelog selects the sections of the release notes published as changelog
+	Changelog ChangelogRules `yaml:"changelog"`
+	// Keep overrides the global retention policy
+	Keep *KeepPolicy `yaml:"keep"`
+
+	AuthorName  string `yaml:"author"`
+	repoAuthor  string
+	repoSummary string
+
+	FriendlyName Localized `yaml:"name"`
 	keyName      string
 
-	Description string `yaml:"description"`
+	Description Localized `yaml:"description"`
 
 	Categories []string `yaml:"categories"`
 
 	AntiFeatures []string `yaml:"anti_features"`
 
-	ReleaseDescription string
+	ReleaseDescription string `yaml:"-"`
 
-	License      string
+	License      string `yaml:"license"`
 	Website      string `yaml:"website"`
 	IssueTracker string `yaml:"issue_tracker"`
 	Debug        bool   `yaml:"debug"`
@@ -44,6 +67,49 @@ func (a *AppInfo) Name() string {
 	return a.keyName
 }
 
+// Locales returns every locale of the name, summary and description
+func (a *AppInfo) Locales() (locales []string) {
+	for _, l := range []Localized{a.FriendlyName, a.Summary, a.Description} {
+		for _, locale := range l.Locales() {
+			if !slices.Contains(locales, locale) {
+				locales = append(locales, locale)
+			}
+		}
+	}
+	slices.Sort(locales)
+	return
+}
+
+// WithRepoMetadata returns a copy of a with the texts of the repository store listings
+// for the locales and fields missing from apps.yaml, then the repository description as summary
+func (a *AppInfo) WithRepoMetadata(r RepoMetadata) *AppInfo {
+	c := *a
+	for _, locale := range sortedLocales(r.Locales) {
+		m := r.Locales[locale]
+		for _, f := range []struct {
+			l     *Localized
+			value string
+		}{{&c.FriendlyName, m.Name}, {&c.Summary, m.Summary}, {&c.Description, m.Description}} {
+			if _, ok := (*f.l)[locale]; !ok && f.value != "" {
+				*f.l = f.l.With(locale, f.value)
+			}
+		}
+	}
+	if _, ok := c.Summary[DefaultLocale]; !ok && a.repoSummary != "" {
+		c.Summary = c.Summary.With(DefaultLocale, a.repoSummary)
+	}
+	return &c
+}
+
+func sortedLocales(m map[string]*LocaleMetadata) []string {
+	locales := make([]string, 0, len(m))
+	for locale := range m {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
 func (a *AppInfo) Author() string {
 	if a.AuthorName != "" {
 		return a.AuthorName
@@ -59,8 +125,17 @@ func ParseAppFile(filepath string) (appFile *AppFile, err error) {
 	}
 	defer f.Close()
 
-	err = yaml.NewDecoder(f).Decode(&appFile)
+	dec := yaml.NewDecoder(f)
+	// Misspelled keys would otherwise be silently ignored
+	dec.KnownFields(true)
+	err = dec.Decode(&appFile)
 	if err != nil {
+		err = fmt.Errorf("%s: %w", filepath, err)
+		return
+	}
+
+	if err = appFile.Keep.compile(); err != nil {
+		err = fmt.Errorf("invalid keep policy: %w", err)
 		return
 	}
 
@@ -78,6 +153,27 @@ func ParseAppFile(filepath string) (appFile *AppFile, err error) {
 			return
 		}
 		a.repoAuthor = split[0]
+
+		if rerr := a.Releases.compile(); rerr != nil {
+			err = fmt.Errorf("invalid releases for app with key=%q: %w", k, rerr)
+			return
+		}
+		if aerr := a.Assets.compile(); aerr != nil {
+			err = fmt.Errorf("invalid assets for app with key=%q: %w", k, aerr)
+			return
+		}
+		if serr := a.Screenshots.compile(); serr != nil {
+			err = fmt.Errorf("invalid screenshots for app with key=%q: %w", k, serr)
+			return
+		}
+		if cerr := a.Changelog.compile(); cerr != nil {
+			err = fmt.Errorf("invalid changelog for app with key=%q: %w", k, cerr)
+			return
+		}
+		if kerr := a.Keep.compile(); kerr != nil {
+			err = fmt.Errorf("invalid keep policy for app with key=%q: %w", k, kerr)
+			return
+		}
 	}
 	return
 }
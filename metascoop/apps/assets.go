@@ -0,0 +1,128 @@
+package apps
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// abis are the variants recognized in the names of split APKs, the longest first
+var abis = []string{"arm64-v8a", "armeabi-v7a", "armeabi", "x86_64", "x86", "universal"}
+
+// AssetRules selects the APKs of a release
+type AssetRules struct {
+	// Include are the patterns an asset name must match, "*.apk" when empty.
+	// Patterns are globs, or regular expressions when written as /regexp/.
+	Include []string `yaml:"include"`
+	// Exclude are the patterns of the assets to ignore, like "*-debug.apk"
+	Exclude []string `yaml:"exclude"`
+	// Split ingests every matching APK, one per ABI, instead of only the first one
+	Split bool `yaml:"split"`
+
+	include []assetPattern
+	exclude []assetPattern
+}
+
+type assetPattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+func (p assetPattern) match(name string) bool {
+	if p.re != nil {
+		return p.re.MatchString(name)
+	}
+	return matchGlob(p.glob, name)
+}
+
+// matchGlob is path.Match where a ** element matches any number of path elements
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "**") {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func compilePatterns(patterns []string) (compiled []assetPattern, err error) {
+	for _, p := range patterns {
+		if len(p) > 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+			var re *regexp.Regexp
+			if re, err = regexp.Compile(p[1 : len(p)-1]); err != nil {
+				err = fmt.Errorf("invalid pattern %q: %w", p, err)
+				return
+			}
+			compiled = append(compiled, assetPattern{re: re})
+			continue
+		}
+		if _, err = path.Match(p, ""); err != nil {
+			err = fmt.Errorf("invalid pattern %q: %w", p, err)
+			return
+		}
+		compiled = append(compiled, assetPattern{glob: p})
+	}
+	return
+}
+
+func (r *AssetRules) compile() (err error) {
+	include := r.Include
+	if len(include) == 0 {
+		include = []string{"*.apk"}
+	}
+	if r.include, err = compilePatterns(include); err != nil {
+		return
+	}
+	r.exclude, err = compilePatterns(r.Exclude)
+	return
+}
+
+func (r *AssetRules) match(name string) bool {
+	for _, p := range r.exclude {
+		if p.match(name) {
+			return false
+		}
+	}
+	// Rules that weren't compiled keep the former behavior
+	if len(r.include) == 0 {
+		return strings.HasSuffix(name, ".apk")
+	}
+	for _, p := range r.include {
+		if p.match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// assetVariant names the split an asset is for, from its ABI or else from its file name
+func assetVariant(name string) string {
+	lower := strings.ToLower(name)
+	for _, abi := range abis {
+		if strings.Contains(lower, abi) {
+			return abi
+		}
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
@@ -3,7 +3,7 @@ package apps
 import (
 	"cmp"
 	"fmt"
-	"os"
+	"metascoop/plan"
 	"path/filepath"
 	"slices"
 	"strings"
@@ -17,14 +17,13 @@ type debugVersion struct {
 	versionName string
 }
 
-func GenerateBadges(appFile, repoDir string) (err error) {
+func GenerateBadges(p *plan.Plan, appFile, repoDir string) (err error) {
 	badges := make(map[string]string)
 	a, err := ParseAppFile(appFile)
 	if err != nil {
 		return err
 	}
-	fdroidIndexFilePath := filepath.Join(repoDir, "index-v1.json")
-	index, err := ReadIndex(fdroidIndexFilePath)
+	index, err := LoadIndex(repoDir)
 	if err != nil {
 		return err
 	}
@@ -63,11 +62,9 @@ func GenerateBadges(appFile, repoDir string) (err error) {
 		})
 		badges[name] = debug[0].versionName
 	}
-	f, err := os.Create(filepath.Join(filepath.Dir(filepath.Dir(repoDir)), "badges.yaml"))
+	b, err := yaml.Marshal(badges)
 	if err != nil {
 		return
 	}
-	defer f.Close()
-	err = yaml.NewEncoder(f).Encode(badges)
-	return
+	return p.WriteFile(filepath.Join(filepath.Dir(filepath.Dir(repoDir)), "badges.yaml"), b)
 }
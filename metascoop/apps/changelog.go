@@ -0,0 +1,185 @@
+package apps
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxChangelogLength is the number of characters of a changelog F-Droid shows
+const MaxChangelogLength = 500
+
+// defaultChangelogExclude drops the contributors section of the notes GitHub generates
+var defaultChangelogExclude = []string{"new contributors"}
+
+// ChangelogRules selects the sections of the release notes published as changelog
+type ChangelogRules struct {
+	// Include are the patterns of the headings of the sections to keep, all of them when empty.
+	// Headings are matched case-insensitively by globs, or regular expressions when written as /regexp/.
+	Include []string `yaml:"include"`
+	// Exclude are the patterns of the headings of the sections to drop, "New Contributors" by default
+	Exclude []string `yaml:"exclude"`
+
+	include []assetPattern
+	exclude []assetPattern
+}
+
+// compileHeadings compiles patterns matching the lowercase headings
+func compileHeadings(patterns []string) ([]assetPattern, error) {
+	lower := make([]string, len(patterns))
+	for i, p := range patterns {
+		if len(p) > 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+			lower[i] = "/(?i)" + p[1:]
+		} else {
+			lower[i] = strings.ToLower(p)
+		}
+	}
+	return compilePatterns(lower)
+}
+
+func (r *ChangelogRules) compile() (err error) {
+	if r.include, err = compileHeadings(r.Include); err != nil {
+		return
+	}
+	exclude := r.Exclude
+	if len(exclude) == 0 {
+		exclude = defaultChangelogExclude
+	}
+	r.exclude, err = compileHeadings(exclude)
+	return
+}
+
+var (
+	htmlComment   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	badge         = regexp.MustCompile(`\[!\[[^\]]*\]\([^)]*\)\]\([^)]*\)`)
+	image         = regexp.MustCompile(`!\[[^\]]*\](\([^)]*\)|\[[^\]]*\])`)
+	link          = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	autolink      = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	lineBreak     = regexp.MustCompile(`(?i)<br\s*/?>`)
+	htmlTag       = regexp.MustCompile(`</?[A-Za-z][^>]*>`)
+	strong        = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	emphasis      = regexp.MustCompile(`(^|[\s(])[*_](\S(?:[^*_]*?\S)?)[*_]([\s).,:;!?]|$)`)
+	strike        = regexp.MustCompile(`~~(.+?)~~`)
+	code          = regexp.MustCompile("`([^`]+)`")
+	heading       = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	quote         = regexp.MustCompile(`^\s*(>\s?)+`)
+	bullet        = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	rule          = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	fullChangelog = regexp.MustCompile(`(?i)^full changelog\b`)
+	blankLines    = regexp.MustCompile(`\n{3,}`)
+)
+
+// inline turns the Markdown spans of a line into plain text
+func inline(line string) string {
+	line = badge.ReplaceAllString(line, "")
+	line = image.ReplaceAllString(line, "")
+	line = link.ReplaceAllString(line, "$1")
+	line = autolink.ReplaceAllString(line, "$1")
+	line = htmlTag.ReplaceAllString(line, "")
+	line = code.ReplaceAllString(line, "$1")
+	line = strong.ReplaceAllString(line, "$2")
+	line = emphasis.ReplaceAllString(line, "$1$2$3")
+	line = strike.ReplaceAllString(line, "$1")
+	return html.UnescapeString(line)
+}
+
+// Render turns Markdown release notes into the plain text changelog of F-Droid, without
+// images, badges and excluded sections, cut at MaxChangelogLength with a link to url
+func (r *ChangelogRules) Render(notes, url string) string {
+	notes = strings.ReplaceAll(notes, "\r\n", "\n")
+	notes = htmlComment.ReplaceAllString(notes, "")
+	notes = lineBreak.ReplaceAllString(notes, "\n")
+
+	// section is a heading, its subsections inherit whether it is included or excluded
+	type section struct {
+		level              int
+		included, excluded bool
+	}
+	// sections are the headings enclosing the current line, the notes before the
+	// first heading are only kept when no section is selected
+	var sections []section
+	current := func() section {
+		if len(sections) == 0 {
+			return section{included: len(r.include) == 0}
+		}
+		return sections[len(sections)-1]
+	}
+	kept := func() bool {
+		s := current()
+		return s.included && !s.excluded
+	}
+
+	var out []string
+	fenced := false
+	for _, line := range strings.Split(notes, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if m := heading.FindStringSubmatch(line); m != nil && !fenced {
+			level, title := len(m[1]), inline(m[2])
+			for len(sections) != 0 && sections[len(sections)-1].level >= level {
+				sections = sections[:len(sections)-1]
+			}
+			parent, lower := current(), strings.ToLower(title)
+			sections = append(sections, section{
+				level:    level,
+				included: parent.included || matchAny(r.include, lower),
+				excluded: parent.excluded || matchAny(r.exclude, lower),
+			})
+			if kept() {
+				out = append(out, "", title)
+			}
+			continue
+		}
+		if !kept() {
+			continue
+		}
+		if fenced {
+			out = append(out, line)
+			continue
+		}
+		if rule.MatchString(line) {
+			continue
+		}
+		line = quote.ReplaceAllString(line, "")
+		line = bullet.ReplaceAllString(line, "$1- ")
+		line = strings.TrimRight(inline(line), " \t")
+		// The link to the full notes is added when the changelog is cut
+		if fullChangelog.MatchString(strings.TrimLeft(line, "- ")) {
+			continue
+		}
+		if strings.TrimLeft(line, "- ") == "" {
+			line = ""
+		}
+		out = append(out, line)
+	}
+
+	text := blankLines.ReplaceAllString(strings.Join(out, "\n"), "\n\n")
+	return TruncateChangelog(strings.TrimSpace(text), url)
+}
+
+// TruncateChangelog cuts text to MaxChangelogLength characters at a line or word
+// boundary, ending it with a link to url where the whole text can be read
+func TruncateChangelog(text, url string) string {
+	if utf8.RuneCountInString(text) <= MaxChangelogLength {
+		return text
+	}
+	suffix := "…"
+	if url != "" {
+		suffix += "\n\nFull notes: " + url
+	}
+	limit := MaxChangelogLength - utf8.RuneCountInString(suffix)
+	if limit <= 0 {
+		return string([]rune(text)[:MaxChangelogLength])
+	}
+	cut := string([]rune(text)[:limit])
+	// Up to the last line, or word, that fits when it doesn't drop most of the text
+	if i := strings.LastIndex(cut, "\n"); i > len(cut)/2 {
+		cut = cut[:i]
+	} else if i := strings.LastIndexAny(cut, " \t"); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \t\n-") + suffix
+}
@@ -0,0 +1,118 @@
+package apps
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRender(t *testing.T) {
+	for _, tc := range []struct {
+		name, notes, want string
+		rules             ChangelogRules
+	}{
+		{
+			name:  "headings",
+			notes: "# Release 1.2\n\nIntro\n\n### Fixes ###\nCrash on start",
+			want:  "Release 1.2\n\nIntro\n\nFixes\nCrash on start",
+		},
+		{
+			name:  "lists",
+			notes: "* one\n+ two\n  - nested\n-\n- three",
+			want:  "- one\n- two\n  - nested\n\n- three",
+		},
+		{
+			name:  "links",
+			notes: "See [the docs](https://example.org/docs) or <https://example.org>",
+			want:  "See the docs or https://example.org",
+		},
+		{
+			name:  "images and badges",
+			notes: "[![build](https://ci/badge.svg)](https://ci) ![screenshot](shot.png)\nText",
+			want:  "Text",
+		},
+		{
+			name:  "inline formatting",
+			notes: "**Bold**, _italic_, ~~gone~~, `code` &amp; <b>html</b>",
+			want:  "Bold, italic, gone, code & html",
+		},
+		{
+			name:  "code blocks",
+			notes: "Run:\n```sh\n# not a heading\n**kept** as is\n```\n> quoted",
+			want:  "Run:\n# not a heading\n**kept** as is\nquoted",
+		},
+		{
+			name:  "comments, rules and breaks",
+			notes: "One<br>Two<!-- hidden -->\n\n---\n\n\n\nThree",
+			want:  "One\nTwo\n\nThree",
+		},
+		{
+			name:  "generated notes",
+			notes: "## What's Changed\n* Fix by @a in #1\n\n## New Contributors\n* @a made their first contribution\n\n**Full Changelog**: https://example.org/compare",
+			want:  "What's Changed\n- Fix by @a in #1",
+		},
+		{
+			name:  "included sections",
+			notes: "Intro\n## Features\n- a\n### Details\n- b\n## Chores\n- c",
+			rules: ChangelogRules{Include: []string{"feat*"}},
+			want:  "Features\n- a\n\nDetails\n- b",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.rules.compile(); err != nil {
+				t.Fatal(err)
+			}
+			if got := tc.rules.Render(tc.notes, ""); got != tc.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTruncateChangelog(t *testing.T) {
+	const url = "https://example.org/releases/v1"
+	suffix := "…\n\nFull notes: " + url
+	words := strings.Repeat("word ", 200)
+
+	for _, tc := range []struct {
+		name, text, url, want string
+	}{
+		{
+			name: "at the limit",
+			text: strings.Repeat("é", MaxChangelogLength),
+			url:  url,
+			want: strings.Repeat("é", MaxChangelogLength),
+		},
+		{
+			name: "word boundary",
+			text: words,
+			url:  url,
+			want: strings.TrimSpace(words[:MaxChangelogLength-utf8.RuneCountInString(suffix)-4]) + suffix,
+		},
+		{
+			name: "line boundary",
+			text: strings.Repeat("a", 300) + "\n" + words,
+			want: strings.Repeat("a", 300) + "…",
+		},
+		{
+			name: "rune boundary",
+			text: strings.Repeat("é", MaxChangelogLength+1),
+			want: strings.Repeat("é", MaxChangelogLength-1) + "…",
+		},
+		{
+			name: "list item",
+			text: strings.Repeat("b", 400) + " -" + strings.Repeat("c", 200),
+			want: strings.Repeat("b", 400) + "…",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := TruncateChangelog(tc.text, tc.url)
+			if got != tc.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tc.want)
+			}
+			if !utf8.ValidString(got) || utf8.RuneCountInString(got) > MaxChangelogLength {
+				t.Errorf("got %d runes or invalid UTF-8", utf8.RuneCountInString(got))
+			}
+		})
+	}
+}
@@ -2,18 +2,26 @@ package apps
 
 import (
 	"os"
+	"path/filepath"
 
 	"gopkg.in/yaml.v3"
 )
 
 type config struct {
-	SdkPath      string `yaml:"sdk_path"`
 	Keystore     string `yaml:"keystore"`
 	Keystorepass string `yaml:"keystorepass"`
 	Keypass      string `yaml:"keypass"`
 	Alias        string `yaml:"repo_keyalias"`
 }
 
+// KeystorePath returns the keystore path, relative paths being resolved against the fdroid directory
+func (c *config) KeystorePath(fdroidDir string) string {
+	if filepath.IsAbs(c.Keystore) {
+		return c.Keystore
+	}
+	return filepath.Join(fdroidDir, c.Keystore)
+}
+
 func ParseFdroidConfig(filepath string) (c *config, err error) {
 	f, err := os.Open(filepath)
 	if err != nil {
@@ -18,6 +18,7 @@ type RepoIndex struct {
 
 type PackageInfo struct {
 	Added            int64    `json:"added"`
+	AntiFeatures     []string `json:"antiFeatures,omitempty"`
 	ApkName          string   `json:"apkName"`
 	Hash             string   `json:"hash"`
 	HashType         string   `json:"hashType"`
@@ -30,6 +31,9 @@ type PackageInfo struct {
 	TargetSdkVersion int      `json:"targetSdkVersion"`
 	VersionCode      int      `json:"versionCode,omitempty"`
 	VersionName      string   `json:"versionName"`
+
+	// AntiFeatureReasons maps an anti-feature id to its localized reason, only index-v2 has them
+	AntiFeatureReasons map[string]Localized `json:"-"`
 }
 
 func (r *RepoIndex) FindLatestPackage(pkgName string) (p PackageInfo, ok bool) {
@@ -0,0 +1,318 @@
+package apps
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultLocale = "en-US"
+
+// Entry is the content of entry.json, which points to the current index-v2
+type Entry struct {
+	Timestamp int64                `json:"timestamp"`
+	Version   int                  `json:"version"`
+	Index     EntryFile            `json:"index"`
+	Diffs     map[string]EntryFile `json:"diffs"`
+}
+
+type EntryFile struct {
+	FileV2
+	NumPackages int `json:"numPackages"`
+}
+
+type FileV2 struct {
+	Name   string `json:"name"`
+	Sha256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+// Localized maps a locale to a value
+type Localized map[string]string
+
+// Get returns the value for the locale, falling back to en-US then to the first locale
+func (l Localized) Get(locale string) string {
+	return localizedGet(l, locale)
+}
+
+func localizedGet[V any](l map[string]V, locale string) (v V) {
+	if v, ok := l[locale]; ok {
+		return v
+	}
+	if v, ok := l[DefaultLocale]; ok {
+		return v
+	}
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return
+	}
+	slices.Sort(keys)
+	return l[keys[0]]
+}
+
+// Locales returns the locales of l in order
+func (l Localized) Locales() []string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// With returns a copy of l with the value of locale set
+func (l Localized) With(locale, value string) Localized {
+	c := make(Localized, len(l)+1)
+	for k, v := range l {
+		c[k] = v
+	}
+	c[locale] = value
+	return c
+}
+
+// UnmarshalYAML accepts either a map of locales or a single string for the default locale
+func (l *Localized) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		var s string
+		if err := n.Decode(&s); err != nil {
+			return err
+		}
+		*l = Localized{DefaultLocale: s}
+		return nil
+	}
+	var m map[string]string
+	if err := n.Decode(&m); err != nil {
+		return err
+	}
+	*l = m
+	return nil
+}
+
+type LocalizedFile map[string]FileV2
+
+// Get returns the file for the locale, with the same fallbacks as Localized
+func (l LocalizedFile) Get(locale string) FileV2 {
+	return localizedGet(l, locale)
+}
+
+type IndexV2 struct {
+	Repo     RepoV2               `json:"repo"`
+	Packages map[string]PackageV2 `json:"packages"`
+}
+
+type RepoV2 struct {
+	Name        Localized     `json:"name"`
+	Description Localized     `json:"description"`
+	Icon        LocalizedFile `json:"icon"`
+	Address     string        `json:"address"`
+	Timestamp   int64         `json:"timestamp"`
+	// AntiFeatures and Categories are keyed by their id
+	AntiFeatures map[string]map[string]interface{} `json:"antiFeatures,omitempty"`
+	Categories   map[string]map[string]interface{} `json:"categories,omitempty"`
+}
+
+type PackageV2 struct {
+	Metadata MetadataV2           `json:"metadata"`
+	Versions map[string]VersionV2 `json:"versions"`
+}
+
+type MetadataV2 struct {
+	Added           int64                          `json:"added"`
+	LastUpdated     int64                          `json:"lastUpdated"`
+	Categories      []string                       `json:"categories"`
+	AuthorName      string                         `json:"authorName"`
+	License         string                         `json:"license"`
+	SourceCode      string                         `json:"sourceCode"`
+	IssueTracker    string                         `json:"issueTracker"`
+	WebSite         string                         `json:"webSite"`
+	Name            Localized                      `json:"name"`
+	Summary         Localized                      `json:"summary"`
+	Description     Localized                      `json:"description"`
+	Icon            LocalizedFile                  `json:"icon"`
+	Screenshots     map[string]map[string][]FileV2 `json:"screenshots"`
+	PreferredSigner string                         `json:"preferredSigner"`
+}
+
+type VersionV2 struct {
+	Added    int64      `json:"added"`
+	File     FileV2     `json:"file"`
+	Manifest ManifestV2 `json:"manifest"`
+	WhatsNew Localized  `json:"whatsNew"`
+	// AntiFeatures maps an anti-feature id to its localized reason
+	AntiFeatures map[string]Localized `json:"antiFeatures"`
+}
+
+type ManifestV2 struct {
+	VersionName string `json:"versionName"`
+	VersionCode int    `json:"versionCode"`
+	UsesSdk     struct {
+		MinSdkVersion    int `json:"minSdkVersion"`
+		TargetSdkVersion int `json:"targetSdkVersion"`
+	} `json:"usesSdk"`
+	Signer struct {
+		Sha256 []string `json:"sha256"`
+	} `json:"signer"`
+	UsesPermission []struct {
+		Name          string `json:"name"`
+		MaxSdkVersion int    `json:"maxSdkVersion,omitempty"`
+	} `json:"usesPermission"`
+	Nativecode []string `json:"nativecode"`
+}
+
+func ReadEntry(path string) (entry *Entry, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	err = json.NewDecoder(f).Decode(&entry)
+
+	return
+}
+
+func ReadIndexV2(path string) (index *IndexV2, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	err = json.NewDecoder(f).Decode(&index)
+
+	return
+}
+
+// LoadIndexV2 follows entry.json in repoDir to the current index-v2
+func LoadIndexV2(repoDir string) (index *IndexV2, err error) {
+	entry, err := ReadEntry(filepath.Join(repoDir, "entry.json"))
+	if err != nil {
+		return
+	}
+	if entry.Index.Name == "" {
+		err = fmt.Errorf("entry.json in %q has no index", repoDir)
+		return
+	}
+	index, err = ReadIndexV2(filepath.Join(repoDir, filepath.FromSlash(strings.TrimPrefix(entry.Index.Name, "/"))))
+	return
+}
+
+// LoadIndex reads the index of repoDir, preferring index-v2 and falling back to index-v1.json
+func LoadIndex(repoDir string) (index *RepoIndex, err error) {
+	v2, err := LoadIndexV2(repoDir)
+	if err == nil {
+		index = v2.ToRepoIndex()
+		return
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	return ReadIndex(filepath.Join(repoDir, "index-v1.json"))
+}
+
+// ToRepoIndex converts the index-v2 into the legacy index-v1 model
+func (i *IndexV2) ToRepoIndex() *RepoIndex {
+	index := &RepoIndex{
+		Repo: map[string]interface{}{
+			"name":        i.Repo.Name.Get(DefaultLocale),
+			"description": i.Repo.Description.Get(DefaultLocale),
+			"address":     i.Repo.Address,
+			"timestamp":   i.Repo.Timestamp,
+		},
+		Apps:     make([]map[string]interface{}, 0, len(i.Packages)),
+		Packages: make(map[string][]PackageInfo),
+	}
+
+	// index-v1 names the icon in the icons directory
+	if icon := i.Repo.Icon.Get(DefaultLocale); icon.Name != "" {
+		index.Repo["icon"] = path.Base(icon.Name)
+	}
+
+	names := make([]string, 0, len(i.Packages))
+	for name := range i.Packages {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		pkg := i.Packages[name]
+		packages := make([]PackageInfo, 0, len(pkg.Versions))
+		for _, v := range pkg.Versions {
+			p := PackageInfo{
+				Added:            v.Added,
+				ApkName:          strings.TrimPrefix(v.File.Name, "/"),
+				Hash:             v.File.Sha256,
+				HashType:         "sha256",
+				MinSdkVersion:    v.Manifest.UsesSdk.MinSdkVersion,
+				Nativecode:       v.Manifest.Nativecode,
+				PackageName:      name,
+				Size:             v.File.Size,
+				TargetSdkVersion: v.Manifest.UsesSdk.TargetSdkVersion,
+				VersionCode:      v.Manifest.VersionCode,
+				VersionName:      v.Manifest.VersionName,
+			}
+			if len(v.Manifest.Signer.Sha256) != 0 {
+				p.Signer = v.Manifest.Signer.Sha256[0]
+			}
+			for id := range v.AntiFeatures {
+				p.AntiFeatures = append(p.AntiFeatures, id)
+			}
+			slices.Sort(p.AntiFeatures)
+			if len(v.AntiFeatures) != 0 {
+				p.AntiFeatureReasons = v.AntiFeatures
+			}
+			packages = append(packages, p)
+		}
+		slices.SortFunc(packages, func(a, b PackageInfo) int {
+			return cmp.Compare(a.VersionCode, b.VersionCode)
+		})
+		index.Packages[name] = packages
+
+		app := map[string]interface{}{
+			"packageName":  name,
+			"added":        pkg.Metadata.Added,
+			"lastUpdated":  pkg.Metadata.LastUpdated,
+			"categories":   pkg.Metadata.Categories,
+			"authorName":   pkg.Metadata.AuthorName,
+			"license":      pkg.Metadata.License,
+			"sourceCode":   pkg.Metadata.SourceCode,
+			"issueTracker": pkg.Metadata.IssueTracker,
+			"webSite":      pkg.Metadata.WebSite,
+			"name":         pkg.Metadata.Name.Get(DefaultLocale),
+			"summary":      pkg.Metadata.Summary.Get(DefaultLocale),
+			"description":  pkg.Metadata.Description.Get(DefaultLocale),
+		}
+		// Same shape as the localized section of index-v1.json
+		localized := make(map[string]interface{})
+		for key, l := range map[string]Localized{"name": pkg.Metadata.Name, "summary": pkg.Metadata.Summary, "description": pkg.Metadata.Description} {
+			for locale, v := range l {
+				if _, ok := localized[locale]; !ok {
+					localized[locale] = make(map[string]interface{})
+				}
+				localized[locale].(map[string]interface{})[key] = v
+			}
+		}
+		app["localized"] = localized
+		if latest, ok := index.FindLatestPackage(name); ok {
+			app["suggestedVersionName"] = latest.VersionName
+			app["suggestedVersionCode"] = fmt.Sprint(latest.VersionCode)
+			if len(latest.AntiFeatures) != 0 {
+				app["antiFeatures"] = latest.AntiFeatures
+			}
+		}
+		index.Apps = append(index.Apps, app)
+	}
+
+	return index
+}
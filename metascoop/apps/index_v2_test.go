@@ -0,0 +1,72 @@
+package apps
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+const indexV2 = `{
+  "repo": {
+    "name": {"en-US": "Repo"},
+    "icon": {"en-US": {"name": "/icons/icon.png", "sha256": "abc", "size": 798}},
+    "address": "https://example.org/fdroid/repo",
+    "timestamp": 1730163030000
+  },
+  "packages": {
+    "org.example.app": {
+      "metadata": {"name": {"en-US": "App", "de": "Anwendung"}},
+      "versions": {
+        "a": {
+          "file": {"name": "/app_v1.apk", "sha256": "a1", "size": 10},
+          "manifest": {"versionName": "1.0", "versionCode": 1}
+        },
+        "b": {
+          "file": {"name": "/app_v2.apk", "sha256": "b2", "size": 20},
+          "manifest": {"versionName": "2.0", "versionCode": 2},
+          "antiFeatures": {
+            "Tracking": {"en-US": "Sends crash reports", "de": "Sendet Absturzberichte"},
+            "NonFreeNet": {}
+          }
+        }
+      }
+    }
+  }
+}`
+
+func TestToRepoIndex(t *testing.T) {
+	var v2 IndexV2
+	if err := json.Unmarshal([]byte(indexV2), &v2); err != nil {
+		t.Fatal(err)
+	}
+	index := v2.ToRepoIndex()
+
+	if icon := index.Repo["icon"]; icon != "icon.png" {
+		t.Errorf("got repo icon %v, want icon.png", icon)
+	}
+
+	pkgs := index.Packages["org.example.app"]
+	if len(pkgs) != 2 || pkgs[0].ApkName != "app_v1.apk" || pkgs[1].ApkName != "app_v2.apk" {
+		t.Fatalf("got packages %+v", pkgs)
+	}
+	if pkgs[0].AntiFeatures != nil || pkgs[0].AntiFeatureReasons != nil {
+		t.Errorf("got anti-features %v %v for a version without any", pkgs[0].AntiFeatures, pkgs[0].AntiFeatureReasons)
+	}
+	if want := []string{"NonFreeNet", "Tracking"}; !slices.Equal(pkgs[1].AntiFeatures, want) {
+		t.Errorf("got anti-features %v, want %v", pkgs[1].AntiFeatures, want)
+	}
+	if reason := pkgs[1].AntiFeatureReasons["Tracking"].Get("de"); reason != "Sendet Absturzberichte" {
+		t.Errorf("got reason %q", reason)
+	}
+
+	if len(index.Apps) != 1 {
+		t.Fatalf("got %d apps, want 1", len(index.Apps))
+	}
+	app := index.Apps[0]
+	if app["name"] != "App" || app["suggestedVersionCode"] != "2" {
+		t.Errorf("got app %v", app)
+	}
+	if af, _ := app["antiFeatures"].([]string); !slices.Equal(af, []string{"NonFreeNet", "Tracking"}) {
+		t.Errorf("got app anti-features %v", app["antiFeatures"])
+	}
+}
@@ -1,33 +1,45 @@
 package apps
 
 import (
-	"context"
 	"fmt"
+	"metascoop/forge"
 	"strings"
 	"unicode"
 
-	"github.com/google/go-github/v61/github"
 	"golang.org/x/text/runes"
 	"golang.org/x/text/transform"
 	"golang.org/x/text/unicode/norm"
 )
 
-func FindAPKRelease(release *github.RepositoryRelease) *github.ReleaseAsset {
-	for _, asset := range release.Assets {
-		if asset.State == nil || *asset.State != "uploaded" {
+// FindAPKAssets returns the release assets selected by rules, only the first one unless rules.Split is set
+func FindAPKAssets(release *forge.Release, rules AssetRules) (assets []*forge.Asset) {
+	for i, asset := range release.Assets {
+		if !rules.match(asset.Name) {
 			continue
 		}
-
-		if asset.Name != nil && strings.HasSuffix(*asset.Name, ".apk") {
-			return asset
+		assets = append(assets, &release.Assets[i])
+		if !rules.Split {
+			break
 		}
 	}
 
-	return nil
+	return
+}
+
+// ReleaseFilename returns the name of the APK published for asset of the release
+func (app *AppInfo) ReleaseFilename(tagName string, asset *forge.Asset) string {
+	if !app.Assets.Split {
+		return GenerateReleaseFilename(app.Name(), tagName, "")
+	}
+	return GenerateReleaseFilename(app.Name(), tagName, assetVariant(asset.Name))
 }
 
-func GenerateReleaseFilename(appName string, tagName string) string {
+// GenerateReleaseFilename returns a file name safe for the repo, variant distinguishes split APKs
+func GenerateReleaseFilename(appName string, tagName string, variant string) string {
 	var normalName = fmt.Sprintf("%s_%s.apk", appName, tagName)
+	if variant != "" {
+		normalName = fmt.Sprintf("%s_%s_%s.apk", appName, tagName, variant)
+	}
 
 	var tc = transform.Chain(norm.NFD, runes.Remove(runes.Predicate(func(r rune) bool {
 		return unicode.Is(unicode.Mn, r)
@@ -51,23 +63,3 @@ func GenerateReleaseFilename(appName string, tagName string) string {
 		return -1
 	}, cleaned)
 }
-
-func ListAllReleases(githubClient *github.Client, appRepoAuthor, appRepoName string) (allReleases []*github.RepositoryRelease, err error) {
-	var currentPage int = 1
-
-	for {
-		rels, _, ierr := githubClient.Repositories.ListReleases(context.Background(), appRepoAuthor, appRepoName, &github.ListOptions{
-			Page:    currentPage,
-			PerPage: 100,
-		})
-		if ierr != nil || len(rels) == 0 {
-			err = ierr
-			break
-		}
-
-		allReleases = append(allReleases, rels...)
-		currentPage++
-	}
-
-	return
-}
@@ -0,0 +1,261 @@
+package apps
+
+import (
+	"fmt"
+	"metascoop/forge"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MaxSummaryLength is the longest summary F-Droid accepts,
+// see https://f-droid.org/en/docs/Build_Metadata_Reference/#Summary
+const MaxSummaryLength = 80
+
+// localePattern matches the locale directories of F-Droid metadata
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z][A-Za-z0-9]+)?$`)
+
+// Categories are the categories known by F-Droid clients
+var Categories = []string{
+	"App Store & Updater", "Bookmark", "Browser", "Calculator", "Calendar & Agenda",
+	"Cloud Storage & File Sync", "Connectivity", "Development", "DNS & Hosts", "Draw",
+	"Ebook Reader", "Email", "File Encryption & Vault", "File Transfer", "Finance Manager",
+	"Forum", "Gallery", "Games", "Graphics", "Habit Tracker", "Icon Pack", "Internet",
+	"Inventory", "Keyboard & IME", "Launcher", "Local Media Player", "Location Tracker & Sharer",
+	"Messaging", "Money", "Multimedia", "Music Practice Tool", "Navigation", "News", "Note",
+	"Online Media Player", "Pass Wallet", "Password & 2FA", "Phone & SMS", "Podcast",
+	"Public Transport", "Reading", "Recipe Manager", "Science & Education", "Security",
+	"Shopping List", "Social Network", "Sports & Health", "System", "Task", "Text Editor",
+	"Theming", "Time", "Translation & Dictionary", "Unit Convertor", "Voice & Video Chat",
+	"VPN & Proxy", "Wallet", "Wallpaper", "Weather", "Workout", "Writing",
+}
+
+// AntiFeatures are the anti-features known by F-Droid clients
+var AntiFeatures = []string{
+	"Ads", "ApplicationDebuggable", "DisabledAlgorithm", "KnownVuln", "NoSourceSince",
+	"NonFreeAdd", "NonFreeAssets", "NonFreeDep", "NonFreeNet", "NSFW", "TetheredNet",
+	"Tracking", "UpstreamNonFree",
+}
+
+// LintError is a problem of the app file at a given position
+type LintError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e LintError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+type linter struct {
+	errs []LintError
+}
+
+func (l *linter) add(n *yaml.Node, format string, args ...interface{}) {
+	l.errs = append(l.errs, LintError{Line: n.Line, Column: n.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+// yamlFields maps the yaml keys of a struct type to their field type
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// checkFields reports unknown and duplicate keys of n decoded into t
+func (l *linter) checkFields(n *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case n.Kind == yaml.MappingNode && (t.Kind() == reflect.Struct || t.Kind() == reflect.Map):
+		var fields map[string]reflect.Type
+		if t.Kind() == reflect.Struct {
+			fields = yamlFields(t)
+		}
+		seen := make(map[string]*yaml.Node)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if first, ok := seen[key.Value]; ok {
+				l.add(key, "duplicate key %q, first defined at line %d", key.Value, first.Line)
+				continue
+			}
+			seen[key.Value] = key
+			if t.Kind() == reflect.Map {
+				l.checkFields(value, t.Elem())
+				continue
+			}
+			ft, ok := fields[key.Value]
+			if !ok {
+				hint := ""
+				if _, ok := fields[strings.ToLower(key.Value)]; ok {
+					hint = fmt.Sprintf(", did you mean %q?", strings.ToLower(key.Value))
+				}
+				l.add(key, "unknown field %q%s", key.Value, hint)
+				continue
+			}
+			l.checkFields(value, ft)
+		}
+	case n.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for _, item := range n.Content {
+			l.checkFields(item, t.Elem())
+		}
+	}
+}
+
+// value returns the value node of key in the mapping n
+func value(n *yaml.Node, key string) (k *yaml.Node, v *yaml.Node) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i], n.Content[i+1]
+		}
+	}
+	return
+}
+
+// checkVocabulary reports the items of the sequence n missing from known
+func (l *linter) checkVocabulary(n *yaml.Node, what string, known []string) {
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, item := range n.Content {
+		if !slices.Contains(known, item.Value) {
+			l.add(item, "unknown %s %q", what, item.Value)
+		}
+	}
+}
+
+func (l *linter) checkApp(key *yaml.Node, n *yaml.Node) {
+	var app AppInfo
+	if err := n.Decode(&app); err != nil {
+		l.add(n, "app %q: %s", key.Value, err.Error())
+		return
+	}
+
+	if _, v := value(n, "git"); v == nil {
+		l.add(key, "app %q has no git URL", key.Value)
+	} else if _, err := url.ParseRequestURI(app.GitURL); err != nil {
+		l.add(v, "invalid git URL %q", app.GitURL)
+	}
+	kinds := []string{forge.KindGitHub, forge.KindGitLab, forge.KindGitea}
+	if _, v := value(n, "forge"); v != nil && !slices.Contains(kinds, app.Forge) {
+		l.add(v, "unknown forge %q, expected one of %v", app.Forge, kinds)
+	}
+
+	for _, field := range []string{"name", "summary", "description"} {
+		if _, v := value(n, field); v != nil && v.Kind == yaml.MappingNode {
+			for i := 0; i < len(v.Content); i += 2 {
+				if !localePattern.MatchString(v.Content[i].Value) {
+					l.add(v.Content[i], "invalid locale %q, expected a code like \"de\" or \"pt-BR\"", v.Content[i].Value)
+				}
+			}
+		}
+	}
+	if _, v := value(n, "summary"); v != nil {
+		for _, locale := range app.Summary.Locales() {
+			if len(app.Summary[locale]) > MaxSummaryLength {
+				l.add(v, "%s summary is %d characters long, the maximum is %d", locale, len(app.Summary[locale]), MaxSummaryLength)
+			}
+		}
+	}
+	_, categories := value(n, "categories")
+	l.checkVocabulary(categories, "category", Categories)
+	_, antiFeatures := value(n, "anti_features")
+	l.checkVocabulary(antiFeatures, "anti-feature", AntiFeatures)
+
+	if k, _ := value(n, "releases"); k != nil {
+		if err := app.Releases.compile(); err != nil {
+			l.add(k, "%s", err.Error())
+		}
+	}
+	if k, _ := value(n, "assets"); k != nil {
+		if err := app.Assets.compile(); err != nil {
+			l.add(k, "%s", err.Error())
+		}
+	}
+	if k, _ := value(n, "screenshots"); k != nil {
+		if err := app.Screenshots.compile(); err != nil {
+			l.add(k, "%s", err.Error())
+		} else if app.Screenshots.Max < 0 || app.Screenshots.MaxSize < 0 {
+			l.add(k, "screenshots max and max_size can't be negative")
+		}
+	}
+	if k, _ := value(n, "changelog"); k != nil {
+		if err := app.Changelog.compile(); err != nil {
+			l.add(k, "%s", err.Error())
+		}
+	}
+	if k, _ := value(n, "keep"); k != nil {
+		if err := app.Keep.compile(); err != nil {
+			l.add(k, "%s", err.Error())
+		}
+	}
+}
+
+// Lint checks the app file at path and returns every problem found with its position
+func Lint(path string) (errs []LintError, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(b, &doc); err != nil {
+		// Syntax errors already carry their line
+		return []LintError{{Message: err.Error()}}, nil
+	}
+	if len(doc.Content) == 0 {
+		return []LintError{{Line: 1, Column: 1, Message: "empty app file"}}, nil
+	}
+	root := doc.Content[0]
+
+	l := &linter{}
+	l.checkFields(root, reflect.TypeOf(AppFile{}))
+
+	if k, v := value(root, "keep"); k != nil {
+		var keep KeepPolicy
+		if err := v.Decode(&keep); err != nil {
+			l.add(v, "%s", err.Error())
+		} else if err := keep.compile(); err != nil {
+			l.add(k, "%s", err.Error())
+		}
+	}
+
+	_, apps := value(root, "apps")
+	if apps == nil || len(apps.Content) == 0 {
+		l.add(root, "no apps defined")
+	} else {
+		for i := 0; i+1 < len(apps.Content); i += 2 {
+			l.checkApp(apps.Content[i], apps.Content[i+1])
+		}
+	}
+
+	slices.SortStableFunc(l.errs, func(a, b LintError) int {
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Column - b.Column
+	})
+	return l.errs, nil
+}
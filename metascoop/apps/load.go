@@ -7,138 +7,310 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
-	"metascoop/git"
-	"net/http"
-	"net/url"
+	"log/slog"
+	"metascoop/apk"
+	"metascoop/forge"
+	"metascoop/logging"
+	"metascoop/plan"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/google/go-github/v61/github"
-	"golang.org/x/mod/semver"
 )
 
 type AppLoader struct {
-	apps         *AppFile
-	githubClient *github.Client
+	apps   *AppFile
+	forges *forge.Registry
+	opts   LoaderOptions
+	// pending holds the release digests to save once the repo is updated
+	pending []appState
 }
 
-func (a *AppFile) NewLoader(githubClient *github.Client) *AppLoader {
-	return &AppLoader{githubClient: githubClient, apps: a}
+type LoaderOptions struct {
+	// QuarantineDir receives the APKs rejected by the signer check
+	QuarantineDir string
+	// Concurrency bounds the concurrent GitHub listings and downloads
+	Concurrency int
+	// StateDir remembers the releases already ingested, apps whose releases didn't change are skipped
+	StateDir string
+	// Plan receives the downloads, they are skipped in a dry run
+	Plan *plan.Plan
 }
 
-func (l *AppLoader) All(repoDir string) (err error) {
-	apkInfoMap := make(map[string]*AppInfo)
-	for _, app := range l.apps.Apps {
-		fmt.Printf("App: %s/%s\n", app.Author(), app.Name())
-		var repo Repo
+func (a *AppFile) NewLoader(forges *forge.Registry, opts LoaderOptions) *AppLoader {
+	return &AppLoader{forges: forges, apps: a, opts: opts}
+}
 
-		repo, err = RepoInfo(app.GitURL)
-		if err != nil {
-			err = fmt.Errorf("error while getting repo info from URL %q: %s", app.GitURL, err.Error())
+// forgeFor returns the forge hosting the app repository
+func (l *AppLoader) forgeFor(app *AppInfo) (f forge.Forge, repo Repo, err error) {
+	repo, err = RepoInfo(app.GitURL)
+	if err != nil {
+		err = fmt.Errorf("error while getting repo info from URL %q: %s", app.GitURL, err.Error())
+		return
+	}
+	f, err = l.forges.For(app.Forge, repo.Host)
+	return
+}
+
+// lookupRepo fills the fallback summary and the license of app from its repository
+func (l *AppLoader) lookupRepo(ctx context.Context, f forge.Forge, app *AppInfo, repo Repo) {
+	slog.InfoContext(ctx, "Looking up repo", "repo", repo.Author+"/"+repo.Name, "host", repo.Host)
+	r, err := f.Repository(ctx, repo.Author, repo.Name)
+	if err != nil {
+		slog.ErrorContext(ctx, "Looking up repo", "repo", repo.Author+"/"+repo.Name, "err", err)
+		return
+	}
+	app.repoSummary = r.Description
+	if r.License != "" {
+		app.License = r.License
+	}
+}
+
+type releaseJob struct {
+	app     *AppInfo
+	forge   forge.Forge
+	repo    Repo
+	release *forge.Release
+}
+
+type releaseResult struct {
+	appNames []string
+	app      *AppInfo
+	err      error
+	// logs are the records of the download, written in the group of the release
+	logs *logging.Buffer
+}
+
+// forEach calls fn for every index in [0, n) with at most Concurrency calls running at once
+func (l *AppLoader) forEach(n int, fn func(i int)) {
+	sem := make(chan struct{}, max(l.opts.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+func (l *AppLoader) All(repoDir string) (err error) {
+	keys := make([]string, 0, len(l.apps.Apps))
+	for key := range l.apps.Apps {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	errs := make([]error, len(keys))
+	appJobs := make([][]releaseJob, len(keys))
+	digests := make([]string, len(keys))
+	logs := make([]*logging.Buffer, len(keys))
+	l.forEach(len(keys), func(i int) {
+		app := l.apps.Apps[keys[i]]
+		var ctx context.Context
+		ctx, logs[i] = logging.WithBuffer(context.Background())
+		appJobs[i], errs[i] = l.listReleases(ctx, app)
+		if errs[i] != nil {
 			return
 		}
+		digests[i] = releasesDigest(app, appJobs[i])
+		if l.unchanged(app, appJobs[i], digests[i], repoDir) {
+			slog.InfoContext(ctx, "Releases are unchanged since the last run, skipping", "app", app.Name())
+			appJobs[i] = nil
+			digests[i] = ""
+		}
+	})
+	// The listings run concurrently, their logs are written app by app
+	for i, key := range keys {
+		end := logging.Group("App " + l.apps.Apps[key].Name())
+		_ = logs[i].Flush()
+		end()
+	}
 
-		log.Printf("Looking up %s/%s on GitHub", repo.Author, repo.Name)
-		var gitHubRepo *github.Repository
-		gitHubRepo, _, err = l.githubClient.Repositories.Get(context.Background(), repo.Author, repo.Name)
+	var jobs []releaseJob
+	for _, j := range appJobs {
+		jobs = append(jobs, j...)
+	}
 
-		if err != nil {
-			log.Printf("Error while looking up repo: %s", err.Error())
+	results := make([]releaseResult, len(jobs))
+	l.forEach(len(jobs), func(i int) {
+		job := jobs[i]
+		// Each release gets its own copy as Download sets the release notes
+		app := *job.app
+		ctx, logs := logging.WithBuffer(context.Background())
+		appNames, err := app.Download(ctx, l.opts.Plan, job.forge, job.release, job.repo, repoDir, l.validator(ctx, &app, job.release.TagName))
+		results[i] = releaseResult{appNames: appNames, app: &app, err: err, logs: logs}
+	})
+
+	// Merge in job order so the result doesn't depend on scheduling
+	apkInfoMap := make(map[string]*AppInfo)
+	failed := make(map[*AppInfo]bool)
+	for i, r := range results {
+		end := logging.Group(fmt.Sprintf("Release %s/%s", jobs[i].app.Name(), jobs[i].release.TagName))
+		_ = r.logs.Flush()
+		if r.err != nil {
+			failed[jobs[i].app] = true
+			slog.Error("Failed", "app", jobs[i].app.Name(), "tag", jobs[i].release.TagName, "err", r.err)
+			errs = append(errs, fmt.Errorf("app %q release %q: %w", jobs[i].app.Name(), jobs[i].release.TagName, r.err))
 		} else {
-			app.Summary = gitHubRepo.GetDescription()
-
-			if gitHubRepo.License != nil && gitHubRepo.License.SPDXID != nil {
-				app.License = *gitHubRepo.License.SPDXID
+			for _, appName := range r.appNames {
+				slog.Info("Ingested", "apk", appName)
+				apkInfoMap[appName] = r.app
 			}
 		}
-		log.Printf("Data from GitHub: summary=%q, license=%q", app.Summary, app.License)
-		var releases []*github.RepositoryRelease
-		releases, err = ListAllReleases(l.githubClient, repo.Author, repo.Name)
-		slices.SortFunc(releases, func(a *github.RepositoryRelease, b *github.RepositoryRelease) int {
-			return semver.Compare(a.GetTagName(), b.GetTagName())
-		})
-		if err != nil {
-			err = fmt.Errorf("error while listing repo releases for %q: %s", app.GitURL, err.Error())
-			return
+		end()
+	}
+
+	for i, key := range keys {
+		app := l.apps.Apps[key]
+		if digests[i] != "" && !failed[app] {
+			l.pending = append(l.pending, appState{app: app, digest: digests[i]})
 		}
-		log.Printf("Received %d releases", len(releases))
+	}
+	l.apps.Apps = apkInfoMap
 
-		for _, release := range releases {
-			fmt.Printf("::group::Release %s\n", release.GetTagName())
-			func() {
-				defer fmt.Println("::endgroup::")
+	return errors.Join(errs...)
+}
 
-				if !release.GetPrerelease() {
-					log.Printf("Skipping non prerelease %q", release.GetTagName())
-					return
-				}
+// listReleases looks up the app repository and returns the releases to ingest
+func (l *AppLoader) listReleases(ctx context.Context, app *AppInfo) (jobs []releaseJob, err error) {
+	slog.InfoContext(ctx, "App", "app", app.Author()+"/"+app.Name())
+	if app.Releases.Channel == ChannelNone {
+		slog.InfoContext(ctx, "Releases are disabled", "app", app.Name())
+		return
+	}
+	f, repo, err := l.forgeFor(app)
+	if err != nil {
+		return
+	}
 
-				if !semver.IsValid(release.GetTagName()) {
-					log.Printf("%q is not a semver", release.GetTagName())
-					return
-				}
-				log.Printf("Working on release with tag name %q", release.GetTagName())
+	l.lookupRepo(ctx, f, app, repo)
+	slog.InfoContext(ctx, "Repo data", "app", app.Name(), "host", repo.Host, "summary", app.repoSummary, "license", app.License)
+	releases, err := f.ListReleases(ctx, repo.Author, repo.Name)
+	if err != nil {
+		err = fmt.Errorf("error while listing repo releases for %q: %s", app.GitURL, err.Error())
+		return
+	}
+	slog.InfoContext(ctx, "Received releases", "app", app.Name(), "count", len(releases))
 
-				apk := FindAPKRelease(release)
-				if apk == nil {
-					log.Printf("Couldn't find a release asset with extension \".apk\"")
-					return
-				}
-				var appName string
-				appName, err = app.Download(l.githubClient, release, repo, repoDir)
-				if err != nil {
-					return
-				}
-				apkInfoMap[appName] = app
-			}()
+	for _, release := range releases {
+		if merr := app.Releases.Match(release); merr != nil {
+			slog.InfoContext(ctx, "Skipping release", "app", app.Name(), "reason", merr)
+			continue
+		}
+
+		if len(FindAPKAssets(release, app.Assets)) == 0 {
+			slog.InfoContext(ctx, "No release asset matches", "app", app.Name(), "tag", release.TagName, "include", app.Assets.Include)
+			continue
 		}
-		l.apps.Apps = apkInfoMap
+		jobs = append(jobs, releaseJob{app: app, forge: f, repo: repo, release: release})
 	}
+	jobs = app.Releases.limit(jobs)
 	return
 }
 
-func (app *AppInfo) Download(githubClient *github.Client, release *github.RepositoryRelease, repo Repo, repoDir string) (appName string, err error) {
-	apk := FindAPKRelease(release)
-	if apk == nil {
-		err = fmt.Errorf("Couldn't find a release asset with extension \".apk\"")
+func (app *AppInfo) Download(ctx context.Context, p *plan.Plan, f forge.Forge, release *forge.Release, repo Repo, repoDir string, validate func(path string) error) (appNames []string, err error) {
+	assets := FindAPKAssets(release, app.Assets)
+	if len(assets) == 0 {
+		err = fmt.Errorf("Couldn't find a release asset matching %v", app.Assets.Include)
 		return
 	}
-	appName = GenerateReleaseFilename(app.Name(), release.GetTagName())
-	app.ReleaseDescription = release.GetBody()
+	app.ReleaseDescription = app.Changelog.Render(release.Body, release.URL)
 	if app.ReleaseDescription != "" {
-		log.Printf("Release notes: %s", app.ReleaseDescription)
+		slog.InfoContext(ctx, "Release notes", "tag", release.TagName, "notes", app.ReleaseDescription)
 	}
-	log.Printf("Target APK name: %s", appName)
-	appTargetPath := filepath.Join(repoDir, appName)
-	_, err = os.Stat(appTargetPath)
-	// If the app file already exists for this version, we continue
-	if !errors.Is(err, os.ErrNotExist) {
-		log.Printf("Already have APK for version %q at %q", release.GetTagName(), appTargetPath)
-		err = nil
-		return
+
+	// Split APKs are separate packages, they can't share a versionCode
+	versionCodes := make(map[int]string)
+	checkVersionCode := func(path string, appName string) error {
+		info, err := apk.ReadInfo(path)
+		if err != nil {
+			return err
+		}
+		if other, ok := versionCodes[info.VersionCode]; ok {
+			return fmt.Errorf("APKs %q and %q share versionCode %d", other, appName, info.VersionCode)
+		}
+		versionCodes[info.VersionCode] = appName
+		return nil
 	}
-	err = nil
-	dlCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+
+	// Split APKs are published together, the ones downloaded so far are removed when another fails
+	var downloaded []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, path := range downloaded {
+			slog.WarnContext(ctx, "Removing APK of an incomplete split release", "path", path)
+			_ = os.Remove(path)
+		}
+		appNames = nil
+	}()
+
+	for _, asset := range assets {
+		appName := app.ReleaseFilename(release.TagName, asset)
+		slog.InfoContext(ctx, "Target APK name", "apk", appName)
+		appTargetPath := filepath.Join(repoDir, appName)
+		_, err = os.Stat(appTargetPath)
+		// If the app file already exists for this version, we continue
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.InfoContext(ctx, "Already have APK", "tag", release.TagName, "path", appTargetPath)
+			if len(assets) > 1 {
+				if err = checkVersionCode(appTargetPath, appName); err != nil {
+					return
+				}
+			}
+			err = nil
+			appNames = append(appNames, appName)
+			continue
+		}
+		if p.DryRun() {
+			p.Record(plan.Action{Op: plan.OpDownload, Path: appTargetPath, Detail: asset.URL})
+			err = nil
+			appNames = append(appNames, appName)
+			continue
+		}
+		if err = app.downloadAsset(ctx, f, release, repo, asset, appTargetPath, func(path string) error {
+			if validate != nil {
+				if err := validate(path); err != nil {
+					return err
+				}
+			}
+			if len(assets) > 1 {
+				return checkVersionCode(path, appName)
+			}
+			return nil
+		}); err != nil {
+			return
+		}
+		downloaded = append(downloaded, appTargetPath)
+		appNames = append(appNames, appName)
+	}
+
+	slog.InfoContext(ctx, "Downloaded app", "tag", release.TagName)
+	return
+}
+
+func (app *AppInfo) downloadAsset(ctx context.Context, f forge.Forge, release *forge.Release, repo Repo, asset *forge.Asset, appTargetPath string, validate func(path string) error) (err error) {
+	dlCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
 	var appStream io.ReadCloser
-	appStream, _, err = githubClient.Repositories.DownloadReleaseAsset(dlCtx, repo.Author, repo.Name, apk.GetID(), http.DefaultClient)
+	appStream, err = f.DownloadAsset(dlCtx, repo.Author, repo.Name, *asset)
 	if err != nil {
-		err = fmt.Errorf("error while downloading app %q (artifact id %d) from from release %q: %s", app.GitURL, apk.GetID(), release.GetTagName(), err.Error())
+		err = fmt.Errorf("error while downloading app %q (artifact id %d) from from release %q: %s", app.GitURL, asset.ID, release.TagName, err.Error())
 		return
 	}
 
-	err = downloadStream(appTargetPath, appStream)
+	err = downloadStream(appTargetPath, appStream, validate)
 	if err != nil {
-		err = fmt.Errorf("error while downloading app %q (artifact id %d) from from release %q to %q: %s", app.GitURL, *apk.ID, *release.TagName, appTargetPath, err.Error())
-		return
+		err = fmt.Errorf("error while downloading app %q (artifact id %d) from from release %q to %q: %s", app.GitURL, asset.ID, release.TagName, appTargetPath, err.Error())
 	}
-
-	log.Printf("Successfully downloaded app for version %q", release.GetTagName())
 	return
 }
 
@@ -148,55 +320,46 @@ func (l *AppLoader) FromRelease(repoDir string, appKey string, version string) (
 		err = fmt.Errorf("unknown app: %s", appKey)
 		return
 	}
-	var repo Repo
-	repo, err = RepoInfo(app.GitURL)
+	f, repo, err := l.forgeFor(app)
 	if err != nil {
-		err = fmt.Errorf("error while getting repo info from URL %q: %s", app.GitURL, err.Error())
 		return
 	}
-	log.Printf("Looking up %s/%s on GitHub", repo.Author, repo.Name)
-	var gitHubRepo *github.Repository
-	gitHubRepo, _, err = l.githubClient.Repositories.Get(context.Background(), repo.Author, repo.Name)
-
-	if err != nil {
-		log.Printf("Error while looking up repo: %s", err.Error())
-	} else {
-		app.Summary = gitHubRepo.GetDescription()
-
-		if gitHubRepo.License != nil && gitHubRepo.License.SPDXID != nil {
-			app.License = *gitHubRepo.License.SPDXID
-		}
-	}
+	l.lookupRepo(context.Background(), f, app, repo)
 	dlCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
-	var release *github.RepositoryRelease
-	release, _, err = l.githubClient.Repositories.GetReleaseByTag(dlCtx, repo.Author, repo.Name, version)
+	var release *forge.Release
+	release, err = f.ReleaseByTag(dlCtx, repo.Author, repo.Name, version)
 	if err != nil {
 		return
 	}
-	var appName string
+	if err = app.Releases.Match(release); err != nil {
+		err = fmt.Errorf("release of %q doesn't follow its rules: %w", appKey, err)
+		return
+	}
+	var appNames []string
 	apkInfoMap := make(map[string]*AppInfo)
-	appName, err = app.Download(l.githubClient, release, repo, repoDir)
-	if _, ok := apkInfoMap[appName]; !ok {
+	appNames, err = app.Download(dlCtx, l.opts.Plan, f, release, repo, repoDir, l.validator(dlCtx, app, release.TagName))
+	for _, appName := range appNames {
 		apkInfoMap[appName] = app
 	}
 	l.apps.Apps = apkInfoMap
 	return
 
 }
-func (l *AppLoader) FromPR(repoDir string, appKey string, prNumber int, artifact int, sha string) (appName string, err error) {
+
+// FromPR downloads the artifact of the pull request prNumber built from the commit sha,
+// the commits since its base are listed in the release notes when withCommits is set
+func (l *AppLoader) FromPR(repoDir string, appKey string, prNumber int, artifact int, sha string, withCommits bool) (appName string, err error) {
 	app, ok := l.apps.Apps[appKey]
 	if !ok {
 		err = fmt.Errorf("unknown app: %s", appKey)
 		return
 	}
-	var repo Repo
-	repo, err = RepoInfo(app.GitURL)
+	f, repo, err := l.forgeFor(app)
 	if err != nil {
-		err = fmt.Errorf("error while getting repo info from URL %q: %s", app.GitURL, err.Error())
 		return
 	}
-	log.Printf("Looking up %s/%s on GitHub", repo.Author, repo.Name)
+	slog.Info("Looking up repo", "repo", repo.Author+"/"+repo.Name, "host", repo.Host)
 	apkInfoMap := make(map[string]*AppInfo)
 
 	appName = fmt.Sprintf("%s_pr_%d_%s.apk", app.Name(), prNumber, sha)
@@ -205,65 +368,100 @@ func (l *AppLoader) FromPR(repoDir string, appKey string, prNumber int, artifact
 	_, err = os.Stat(appTargetPath)
 	// If the app file already exists for this version, we continue
 	if !errors.Is(err, os.ErrNotExist) {
-		log.Printf("Already have APK for version %q at %q", appName, appTargetPath)
+		slog.Info("Already have APK", "apk", appName, "path", appTargetPath)
+	} else if l.opts.Plan.DryRun() {
+		err = nil
+		l.opts.Plan.Record(plan.Action{Op: plan.OpDownload, Path: appTargetPath, Detail: fmt.Sprintf("artifact %d of %s", artifact, app.GitURL)})
 	} else {
-		err = l.downloadArtifact(appTargetPath, repo.Author, repo.Name, artifact)
+		err = l.downloadArtifact(f, app, appTargetPath, repo.Author, repo.Name, artifact)
 		if err != nil {
 			return
 		}
 	}
 
-	var str string
-	str, err = git.GetPrCommit(app.GitURL, prNumber, sha)
+	dlCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	var commit *forge.Commit
+	commit, err = f.Commit(dlCtx, repo.Author, repo.Name, sha)
 	if err != nil {
-		log.Printf("Error cloning %s for %d on %s: %s\n", app.GitURL, prNumber, sha, err.Error())
+		slog.Error("Looking up PR commit", "url", app.GitURL, "pr", prNumber, "sha", sha, "err", err)
 		return
 	}
-	dlCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-	var pr *github.PullRequest
-	pr, _, err = l.githubClient.PullRequests.Get(dlCtx, repo.Author, repo.Name, prNumber)
+	var pr *forge.PullRequest
+	pr, err = f.PullRequest(dlCtx, repo.Author, repo.Name, prNumber)
 	if err != nil {
 		return
 	}
-	app.Summary = fmt.Sprintf(`PR #%d
-%s`, prNumber, pr.GetBody())
-	app.FriendlyName = fmt.Sprintf("%s PR: %d", app.FriendlyName, prNumber)
-	app.ReleaseDescription = fmt.Sprintf(`Commit (%s): %s`, sha, str)
+	var commits []*forge.Commit
+	if withCommits {
+		if commits, err = f.PullRequestCommits(dlCtx, repo.Author, repo.Name, prNumber); err != nil {
+			slog.Warn("Listing PR commits", "url", app.GitURL, "pr", prNumber, "err", err)
+			err = nil
+		}
+	}
+	app.Summary = Localized{DefaultLocale: fmt.Sprintf(`PR #%d
+%s`, prNumber, pr.Body)}
+	name := app.FriendlyName
+	app.FriendlyName = Localized{DefaultLocale: fmt.Sprintf("%s PR: %d", name.Get(DefaultLocale), prNumber)}
+	for _, locale := range name.Locales() {
+		app.FriendlyName[locale] = fmt.Sprintf("%s PR: %d", name[locale], prNumber)
+	}
+	app.ReleaseDescription = TruncateChangelog(prReleaseNotes(commit, pr, commits), pr.URL)
 	apkInfoMap[appName] = app
 	l.apps.Apps = apkInfoMap
 
 	return
 }
 
-func (l *AppLoader) downloadArtifact(appTargetPath, author, name string, artifact int) (err error) {
-	dlCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-	var u *url.URL
-	u, _, err = l.githubClient.Actions.DownloadArtifact(dlCtx, author, name, int64(artifact), 1)
-	if err != nil {
-		return
+// prReleaseNotes describes the build of a pull request from its commit, title, labels
+// and, when listed, its commits
+func prReleaseNotes(commit *forge.Commit, pr *forge.PullRequest, commits []*forge.Commit) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Commit (%s): %s\n", commit.SHA, commit.Subject())
+	if commit.Author != "" {
+		fmt.Fprintf(&sb, "By %s on %s\n", commit.Author, commit.Date.Format(time.DateOnly))
+	}
+	fmt.Fprintf(&sb, "\nPR #%d: %s\n", pr.Number, pr.Title)
+	if len(pr.Labels) != 0 {
+		fmt.Fprintf(&sb, "Labels: %s\n", strings.Join(pr.Labels, ", "))
+	}
+	if len(commits) != 0 {
+		sb.WriteString("\nWhat changed:\n")
+		for _, c := range commits {
+			fmt.Fprintf(&sb, "- %s (%.7s)\n", c.Subject(), c.SHA)
+		}
 	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
 
-	var req *http.Request
-	var resp *http.Response
-	if req, err = http.NewRequestWithContext(context.Background(),
-		http.MethodGet,
-		u.String(),
-		http.NoBody); err != nil {
+// PullRequest looks up a pull request of the app repository on its forge
+func (l *AppLoader) PullRequest(appKey string, number int) (pr *forge.PullRequest, err error) {
+	app, ok := l.apps.Apps[appKey]
+	if !ok {
+		err = fmt.Errorf("unknown app: %s", appKey)
 		return
 	}
-	if resp, err = http.DefaultClient.Do(req); err != nil {
+	f, repo, err := l.forgeFor(app)
+	if err != nil {
 		return
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return f.PullRequest(ctx, repo.Author, repo.Name, number)
+}
 
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("bad status: %s", resp.Status)
+func (l *AppLoader) downloadArtifact(f forge.Forge, app *AppInfo, appTargetPath, author, name string, artifact int) (err error) {
+	dlCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	var rc io.ReadCloser
+	rc, err = f.DownloadArtifact(dlCtx, author, name, int64(artifact))
+	if err != nil {
 		return
 	}
+	defer rc.Close()
+
 	var body []byte
-	body, err = io.ReadAll(resp.Body)
+	body, err = io.ReadAll(rc)
 	if err != nil {
 		return
 	}
@@ -279,14 +477,15 @@ func (l *AppLoader) downloadArtifact(appTargetPath, author, name string, artifac
 			if err != nil {
 				return
 			}
-			downloadStream(appTargetPath, rc)
+			err = downloadStream(appTargetPath, rc, l.validator(dlCtx, app, ""))
 			break
 		}
 	}
 	return
 }
 
-func downloadStream(targetFile string, rc io.ReadCloser) (err error) {
+// downloadStream writes rc to targetFile, the file is only moved in place once validate accepts it
+func downloadStream(targetFile string, rc io.ReadCloser, validate func(path string) error) (err error) {
 	defer rc.Close()
 
 	targetTemp := targetFile + ".tmp"
@@ -309,6 +508,14 @@ func downloadStream(targetFile string, rc io.ReadCloser) (err error) {
 		return
 	}
 
+	if validate != nil {
+		if err = validate(targetTemp); err != nil {
+			// The validator may already have quarantined the file
+			_ = os.Remove(targetTemp)
+			return
+		}
+	}
+
 	err = os.Rename(targetTemp, targetFile)
 	return
 }
@@ -0,0 +1,49 @@
+package apps
+
+import (
+	"context"
+	"io"
+	"metascoop/forge"
+	"metascoop/plan"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fixtureForge serves the fixture APK for every asset
+type fixtureForge struct {
+	forge.Forge
+}
+
+func (fixtureForge) DownloadAsset(ctx context.Context, owner, name string, asset forge.Asset) (io.ReadCloser, error) {
+	return os.Open(filepath.Join("..", "apk", "testdata", "app.apk"))
+}
+
+func TestDownloadSplitIsAtomic(t *testing.T) {
+	repoDir := t.TempDir()
+	app := &AppInfo{keyName: "fixture", Assets: AssetRules{Split: true}}
+	release := &forge.Release{
+		TagName: "v1.2.3",
+		Assets: []forge.Asset{
+			{ID: 1, Name: "app-arm64-v8a.apk"},
+			{ID: 2, Name: "app-x86_64.apk"},
+		},
+	}
+
+	// Both assets are the same APK, the second fails as it shares the versionCode of the first
+	appNames, err := app.Download(context.Background(), plan.New(false), fixtureForge{}, release, Repo{}, repoDir, nil)
+	if err == nil || !strings.Contains(err.Error(), "share versionCode") {
+		t.Fatalf("got error %v, want a shared versionCode", err)
+	}
+	if appNames != nil {
+		t.Errorf("got app names %v, want none", appNames)
+	}
+	entries, err := os.ReadDir(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Errorf("%s left in the repo", e.Name())
+	}
+}
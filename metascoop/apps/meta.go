@@ -1,6 +1,8 @@
 package apps
 
 import (
+	"bytes"
+	"metascoop/plan"
 	"os"
 
 	"gopkg.in/yaml.v3"
@@ -18,23 +20,14 @@ func ReadMetaFile(path string) (d map[string]interface{}, err error) {
 	return
 }
 
-func WriteMetaFile(path string, data map[string]interface{}) (err error) {
-	tmpPath := path + ".tmp"
-	f, err := os.Create(tmpPath)
-	if err != nil {
-		return
-	}
-
-	err = yaml.NewEncoder(f).Encode(data)
-	if err != nil {
-		_ = f.Close()
+func WriteMetaFile(p *plan.Plan, path string, data map[string]interface{}) (err error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	if err = enc.Encode(data); err != nil {
 		return
 	}
-
-	err = f.Close()
-	if err != nil {
+	if err = enc.Close(); err != nil {
 		return
 	}
-
-	return os.Rename(tmpPath, path)
+	return p.WriteFile(path, buf.Bytes())
 }
@@ -0,0 +1,126 @@
+package apps
+
+import (
+	"cmp"
+	"fmt"
+	"metascoop/forge"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// Release channels of ReleaseRules
+const (
+	ChannelPrerelease = "prerelease"
+	ChannelStable     = "stable"
+	ChannelBoth       = "both"
+	// ChannelNone is for apps only published from PRs
+	ChannelNone = "none"
+)
+
+// ReleaseRules selects the releases of an app to ingest
+type ReleaseRules struct {
+	// Channel is prerelease (the default), stable, both or none
+	Channel string `yaml:"channel"`
+	// Tag is a regular expression the release tag must match
+	Tag string `yaml:"tag"`
+	// Version is a space or comma separated semver constraint, like ">=0.15.0 <1.0.0".
+	// Prereleases are checked using their core version.
+	Version string `yaml:"version"`
+	// Drafts includes draft releases
+	Drafts bool `yaml:"drafts"`
+	// Latest keeps only the N most recent matching releases, all are kept when 0
+	Latest int `yaml:"latest"`
+
+	tag     *regexp.Regexp
+	version version.Constraints
+}
+
+// compile checks the rules and prepares the tag pattern and version constraint
+func (r *ReleaseRules) compile() (err error) {
+	switch r.Channel {
+	case "":
+		r.Channel = ChannelPrerelease
+	case ChannelPrerelease, ChannelStable, ChannelBoth, ChannelNone:
+	default:
+		return fmt.Errorf("unknown release channel %q, expected one of %s, %s, %s or %s", r.Channel, ChannelPrerelease, ChannelStable, ChannelBoth, ChannelNone)
+	}
+	if r.Latest < 0 {
+		return fmt.Errorf("latest must not be negative, got %d", r.Latest)
+	}
+	if r.Tag != "" {
+		if r.tag, err = regexp.Compile(r.Tag); err != nil {
+			return fmt.Errorf("invalid tag pattern %q: %w", r.Tag, err)
+		}
+	}
+	if r.Version != "" {
+		if r.version, err = parseConstraint(r.Version); err != nil {
+			return fmt.Errorf("invalid version constraint %q: %w", r.Version, err)
+		}
+	}
+	return
+}
+
+// parseConstraint accepts constraints separated by spaces as well as commas
+func parseConstraint(s string) (version.Constraints, error) {
+	var parts []string
+	op := ""
+	for _, field := range strings.Fields(strings.ReplaceAll(s, ",", " ")) {
+		// An operator written apart from its version
+		if strings.Trim(field, "<>=!~") == "" {
+			op += field
+			continue
+		}
+		parts = append(parts, op+field)
+		op = ""
+	}
+	if op != "" {
+		return nil, fmt.Errorf("operator %q has no version", op)
+	}
+	return version.NewConstraint(strings.Join(parts, ","))
+}
+
+// Match returns why release isn't selected by the rules, or nil when it is
+func (r *ReleaseRules) Match(release *forge.Release) error {
+	switch {
+	case release.Draft && !r.Drafts:
+		return fmt.Errorf("%q is a draft", release.TagName)
+	case r.Channel == ChannelNone:
+		return fmt.Errorf("releases are disabled")
+	case r.Channel == ChannelPrerelease && !release.Prerelease:
+		return fmt.Errorf("%q isn't a prerelease", release.TagName)
+	case r.Channel == ChannelStable && release.Prerelease:
+		return fmt.Errorf("%q is a prerelease", release.TagName)
+	}
+
+	if r.tag != nil && !r.tag.MatchString(release.TagName) {
+		return fmt.Errorf("%q doesn't match tag pattern %q", release.TagName, r.Tag)
+	}
+
+	v, err := version.NewSemver(release.TagName)
+	if err != nil {
+		return fmt.Errorf("%q is not a semver", release.TagName)
+	}
+	if r.version != nil && !r.version.Check(v.Core()) {
+		return fmt.Errorf("%q doesn't satisfy %q", release.TagName, r.Version)
+	}
+	return nil
+}
+
+// limit sorts the selected jobs by version and keeps the Latest ones
+func (r *ReleaseRules) limit(jobs []releaseJob) []releaseJob {
+	slices.SortStableFunc(jobs, func(a, b releaseJob) int {
+		va, _ := version.NewSemver(a.release.TagName)
+		vb, _ := version.NewSemver(b.release.TagName)
+		if va == nil || vb == nil {
+			return cmp.Compare(a.release.TagName, b.release.TagName)
+		}
+		return va.Compare(vb)
+	})
+	if r.Latest > 0 && len(jobs) > r.Latest {
+		jobs = jobs[len(jobs)-r.Latest:]
+	}
+	return jobs
+}
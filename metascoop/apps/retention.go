@@ -0,0 +1,190 @@
+package apps
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"metascoop/plan"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+// KeepPolicy decides which versions of an app stay in the repo.
+// A version is kept when any of the rules keeps it, everything is kept when no rule is set.
+// The most recent version is always kept unless PruneLatest is set.
+type KeepPolicy struct {
+	// Last keeps the N most recent versions
+	Last int `yaml:"last"`
+	// NewerThan keeps the versions added after a date (2006-01-02) or within a number of days (90d)
+	NewerThan string `yaml:"newer_than"`
+	// LatestStable always keeps the most recent version without a prerelease
+	LatestStable bool `yaml:"latest_stable"`
+	// PruneLatest lets the rules prune the most recent version, which F-Droid suggests
+	PruneLatest bool `yaml:"prune_latest"`
+	// Archive moves the pruned APKs to the archive repo instead of deleting them
+	Archive bool `yaml:"archive"`
+
+	since  time.Time
+	within time.Duration
+}
+
+func (k *KeepPolicy) compile() (err error) {
+	if k == nil {
+		return
+	}
+	if k.Last < 0 {
+		return fmt.Errorf("last must not be negative, got %d", k.Last)
+	}
+	if k.NewerThan == "" {
+		return
+	}
+	if days, ok := strings.CutSuffix(k.NewerThan, "d"); ok {
+		var n int
+		if n, err = strconv.Atoi(days); err != nil || n <= 0 {
+			return fmt.Errorf("invalid newer_than %q, expected a number of days like 90d", k.NewerThan)
+		}
+		k.within = time.Duration(n) * 24 * time.Hour
+		return
+	}
+	if k.since, err = time.Parse(time.DateOnly, k.NewerThan); err != nil {
+		return fmt.Errorf("invalid newer_than %q, expected a date like 2006-01-02 or days like 90d", k.NewerThan)
+	}
+	return
+}
+
+func (k *KeepPolicy) cutoff(now time.Time) time.Time {
+	if k.within != 0 {
+		return now.Add(-k.within)
+	}
+	return k.since
+}
+
+// policyFor returns the policy of the app publishing apkName, falling back to the global one
+func (a *AppFile) policyFor(apkName string) *KeepPolicy {
+	if app, ok := a.Apps[a.ownerOf(apkName)]; ok && app.Keep != nil {
+		return app.Keep
+	}
+	return a.Keep
+}
+
+type versionGroup struct {
+	name        string
+	versionCode int
+	added       int64
+	packages    []PackageInfo
+}
+
+// retained returns the versions of a package to keep, split APKs of a version share its fate
+func (k *KeepPolicy) retained(pkgs []PackageInfo, now time.Time) map[string]bool {
+	groups := make(map[string]*versionGroup)
+	for _, p := range pkgs {
+		g, ok := groups[p.VersionName]
+		if !ok {
+			g = &versionGroup{name: p.VersionName}
+			groups[p.VersionName] = g
+		}
+		g.versionCode = max(g.versionCode, p.VersionCode)
+		g.added = max(g.added, p.Added)
+		g.packages = append(g.packages, p)
+	}
+	versions := make([]*versionGroup, 0, len(groups))
+	for _, g := range groups {
+		versions = append(versions, g)
+	}
+	// Most recent first
+	slices.SortFunc(versions, func(a, b *versionGroup) int {
+		return cmp.Compare(b.versionCode, a.versionCode)
+	})
+
+	keep := make(map[string]bool)
+	cutoff := k.cutoff(now)
+	stableKept := false
+	for i, v := range versions {
+		if k.Last == 0 && cutoff.IsZero() {
+			keep[v.name] = true
+		}
+		if i == 0 && !k.PruneLatest {
+			keep[v.name] = true
+		}
+		if k.Last > 0 && i < k.Last {
+			keep[v.name] = true
+		}
+		if !cutoff.IsZero() && time.UnixMilli(v.added).After(cutoff) {
+			keep[v.name] = true
+		}
+		if k.LatestStable && !stableKept {
+			if sv, err := version.NewVersion(v.name); err == nil && sv.Prerelease() == "" {
+				keep[v.name] = true
+				stableKept = true
+			}
+		}
+	}
+	return keep
+}
+
+// Prune applies the keep policies to the packages of index. The pruned APKs are deleted or archived,
+// their changelogs in metadataDir removed, and they are dropped from index so they get no Builds entry.
+func (a *AppFile) Prune(p *plan.Plan, index *RepoIndex, repoDir string, metadataDir string) (pruned []PackageInfo, err error) {
+	now := time.Now()
+	archiveDir := filepath.Join(filepath.Dir(repoDir), "archive")
+
+	names := make([]string, 0, len(index.Packages))
+	for name := range index.Packages {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		var kept []PackageInfo
+		pkgs := index.Packages[name]
+		if len(pkgs) == 0 {
+			continue
+		}
+		// Versions of a package are published by one app, the first APK is enough to find it
+		policy := a.policyFor(pkgs[0].ApkName)
+		if policy == nil {
+			continue
+		}
+		keep := policy.retained(pkgs, now)
+		for _, pkg := range pkgs {
+			if keep[pkg.VersionName] {
+				kept = append(kept, pkg)
+				continue
+			}
+			apkPath := filepath.Join(repoDir, pkg.ApkName)
+			if policy.Archive {
+				slog.Info("Archiving", "apk", pkg.ApkName)
+				err = p.Move(apkPath, filepath.Join(archiveDir, pkg.ApkName))
+			} else {
+				slog.Info("Deleting", "apk", pkg.ApkName)
+				err = p.Remove(apkPath)
+			}
+			if err != nil && !os.IsNotExist(err) {
+				err = fmt.Errorf("pruning %q: %w", pkg.ApkName, err)
+				return
+			}
+			err = nil
+
+			changelogs, _ := filepath.Glob(filepath.Join(metadataDir, name, "*", "changelogs", fmt.Sprintf("%d.txt", pkg.VersionCode)))
+			for _, changelog := range changelogs {
+				slog.Info("Deleting", "changelog", changelog)
+				if err = p.Remove(changelog); err != nil {
+					return
+				}
+			}
+			pruned = append(pruned, pkg)
+		}
+		if len(kept) == 0 {
+			delete(index.Packages, name)
+		} else {
+			index.Packages[name] = kept
+		}
+	}
+	return
+}
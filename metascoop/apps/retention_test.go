@@ -0,0 +1,47 @@
+package apps
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetained(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := func(d int) int64 { return now.AddDate(0, 0, -d).UnixMilli() }
+	pkgs := []PackageInfo{
+		{VersionName: "1.0.0", VersionCode: 1, Added: day(400)},
+		{VersionName: "1.1.0", VersionCode: 2, Added: day(200)},
+		// Split APKs of one version
+		{VersionName: "2.0.0-beta1", VersionCode: 3, Added: day(150)},
+		{VersionName: "2.0.0-beta1", VersionCode: 3, Added: day(150)},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		policy KeepPolicy
+		want   []string
+	}{
+		{"no rule", KeepPolicy{}, []string{"1.0.0", "1.1.0", "2.0.0-beta1"}},
+		{"last", KeepPolicy{Last: 2}, []string{"1.1.0", "2.0.0-beta1"}},
+		{"newer_than keeps the latest", KeepPolicy{NewerThan: "90d"}, []string{"2.0.0-beta1"}},
+		{"newer_than date", KeepPolicy{NewerThan: "2023-06-01"}, []string{"1.1.0", "2.0.0-beta1"}},
+		{"latest_stable", KeepPolicy{NewerThan: "90d", LatestStable: true}, []string{"1.1.0", "2.0.0-beta1"}},
+		{"prune_latest", KeepPolicy{NewerThan: "90d", PruneLatest: true}, nil},
+		{"prune_latest with latest_stable", KeepPolicy{Last: 1, LatestStable: true, PruneLatest: true}, []string{"1.1.0", "2.0.0-beta1"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.policy.compile(); err != nil {
+				t.Fatal(err)
+			}
+			keep := tc.policy.retained(pkgs, now)
+			if len(keep) != len(tc.want) {
+				t.Errorf("kept %v, want %v", keep, tc.want)
+			}
+			for _, name := range tc.want {
+				if !keep[name] {
+					t.Errorf("%s not kept, kept %v", name, keep)
+				}
+			}
+		})
+	}
+}
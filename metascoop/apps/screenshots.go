@@ -1,45 +1,391 @@
 package apps
 
 import (
+	"cmp"
 	"io/fs"
+	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
+// Screenshot kinds, named after their directory in the F-Droid metadata tree
+const (
+	PhoneScreenshots     = "phoneScreenshots"
+	SevenInchScreenshots = "sevenInchScreenshots"
+	TenInchScreenshots   = "tenInchScreenshots"
+	TvScreenshots        = "tvScreenshots"
+	WearScreenshots      = "wearScreenshots"
+)
+
+// ScreenshotKinds lists the screenshot kinds in the order they are published
+var ScreenshotKinds = []string{PhoneScreenshots, SevenInchScreenshots, TenInchScreenshots, TvScreenshots, WearScreenshots}
+
+// tripleTKinds maps the graphics directories of Triple-T to the screenshot kinds
+var tripleTKinds = map[string]string{
+	"phone-screenshots":      PhoneScreenshots,
+	"seven-inch-screenshots": SevenInchScreenshots,
+	"ten-inch-screenshots":   TenInchScreenshots,
+	"tv-screenshots":         TvScreenshots,
+	"wear-screenshots":       WearScreenshots,
+}
+
+// defaultScreenshotExclude skips the vendored and test files matched by name
+var defaultScreenshotExclude = []string{
+	"**/node_modules/**", "**/vendor/**", "**/third_party/**", "**/build/**",
+	"**/test/**", "**/androidTest/**", "**/testdata/**", "**/fixtures/**",
+}
+
+// ScreenshotRules selects the screenshots of a repository
+type ScreenshotRules struct {
+	// Include are the patterns of the paths, relative to the repository, used as phone
+	// screenshots instead of the store listing ones and those with screenshot in their path.
+	// Patterns are globs where ** matches any directories, or regular expressions when written as /regexp/.
+	Include []string `yaml:"include"`
+	// Exclude are the patterns of the paths to ignore, test and vendored directories by default
+	Exclude []string `yaml:"exclude"`
+	// Max is the maximum number of screenshots of each kind and locale, all of them when 0
+	Max int `yaml:"max"`
+	// MaxSize is the longest side of the screenshots in pixels, larger ones are downscaled
+	MaxSize int `yaml:"max_size"`
+
+	include []assetPattern
+	exclude []assetPattern
+}
+
+func (r *ScreenshotRules) compile() (err error) {
+	if r.include, err = compilePatterns(r.Include); err != nil {
+		return
+	}
+	exclude := r.Exclude
+	if len(exclude) == 0 {
+		exclude = defaultScreenshotExclude
+	}
+	r.exclude, err = compilePatterns(exclude)
+	return
+}
+
+// metadataPaths are the sparse checkout patterns of the store listings and of the
+// paths with screenshot in them, in any case
+var metadataPaths = []string{"metadata/", "play/", "*[sS][cC][rR][eE][eE][nN][sS][hH][oO][tT]*"}
+
+// CheckoutPaths returns the gitignore-like patterns of the files FindMetadata reads,
+// none when an include pattern is a regular expression as the whole tree is needed
+func (r *ScreenshotRules) CheckoutPaths() []string {
+	paths := slices.Clone(metadataPaths)
+	for _, p := range r.include {
+		if p.re != nil {
+			return nil
+		}
+		paths = append(paths, "/"+p.glob)
+	}
+	return paths
+}
+
+func matchAny(patterns []assetPattern, name string) bool {
+	for _, p := range patterns {
+		if p.match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// LocaleMetadata is the store listing of one locale found in a repository
+type LocaleMetadata struct {
+	Name        string
+	Summary     string
+	Description string
+	// Changelogs maps a versionCode to its changelog file, 0 is the default one
+	Changelogs     map[int]string
+	Icon           string
+	FeatureGraphic string
+	// Screenshots maps a screenshot kind to its files
+	Screenshots map[string][]string
+}
+
+// Changelog returns the changelog file of versionCode, or the default one
+func (m *LocaleMetadata) Changelog(versionCode int) (path string, ok bool) {
+	if path, ok = m.Changelogs[versionCode]; ok {
+		return
+	}
+	path, ok = m.Changelogs[0]
+	return
+}
+
 type RepoMetadata struct {
-	Screenshots []string
+	Locales map[string]*LocaleMetadata
+}
+
+func (r *RepoMetadata) locale(locale string) *LocaleMetadata {
+	m, ok := r.Locales[locale]
+	if !ok {
+		m = &LocaleMetadata{Changelogs: make(map[int]string), Screenshots: make(map[string][]string)}
+		r.Locales[locale] = m
+	}
+	return m
+}
+
+// HasIcon tells whether any locale has an icon
+func (r *RepoMetadata) HasIcon() bool {
+	for _, m := range r.Locales {
+		if m.Icon != "" {
+			return true
+		}
+	}
+	return false
 }
 
 var imageSuffixes = map[string]bool{
 	"png":  true,
 	"jpg":  true,
 	"jpeg": true,
+	"webp": true,
 }
 
 func hasImageSuffix(path string) bool {
 	return imageSuffixes[strings.TrimPrefix(filepath.Ext(path), ".")]
 }
 
-func FindMetadata(clonedRepoPath string) (r RepoMetadata, err error) {
+// localeOf returns the locale of a path below a metadata/<locale> or android/<locale>
+// directory, like fastlane/metadata/android/de-DE/images, and the path within it
+func localeOf(rel string) (locale string, sub []string, ok bool) {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := len(parts) - 2; i > 0; i-- {
+		if (parts[i-1] == "metadata" || parts[i-1] == "android") && localePattern.MatchString(parts[i]) {
+			return parts[i], parts[i+1:], true
+		}
+	}
+	return DefaultLocale, nil, false
+}
+
+// tripleTOf returns the locale of a path below the play directory of the Triple-T
+// gradle plugin, like app/src/main/play/listings/de-DE, and the path within it
+func tripleTOf(rel string) (dir, locale string, sub []string, ok bool) {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 0; i+3 < len(parts); i++ {
+		if parts[i] == "play" && (parts[i+1] == "listings" || parts[i+1] == "release-notes") && localePattern.MatchString(parts[i+2]) {
+			return parts[i+1], parts[i+2], parts[i+3:], true
+		}
+	}
+	return
+}
+
+func readText(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// addFastlane records a file of a fastlane locale directory
+func (m *LocaleMetadata) addFastlane(path string, sub []string) bool {
+	switch {
+	case len(sub) == 1 && (sub[0] == "title.txt" || sub[0] == "name.txt"):
+		m.Name = readText(path)
+	case len(sub) == 1 && (sub[0] == "short_description.txt" || sub[0] == "summary.txt"):
+		m.Summary = readText(path)
+	case len(sub) == 1 && (sub[0] == "full_description.txt" || sub[0] == "description.txt"):
+		m.Description = readText(path)
+	case len(sub) == 2 && sub[0] == "changelogs" && filepath.Ext(sub[1]) == ".txt":
+		name := strings.TrimSuffix(sub[1], ".txt")
+		if versionCode, err := strconv.Atoi(name); err == nil && versionCode > 0 {
+			m.Changelogs[versionCode] = path
+		} else if name == "default" {
+			m.Changelogs[0] = path
+		}
+	case len(sub) == 2 && sub[0] == "images" && sub[1] == "icon.png":
+		m.Icon = path
+	case len(sub) == 2 && sub[0] == "images" && strings.TrimSuffix(sub[1], filepath.Ext(sub[1])) == "featureGraphic" && hasImageSuffix(path):
+		m.FeatureGraphic = path
+	case len(sub) == 3 && sub[0] == "images" && hasImageSuffix(path):
+		for _, kind := range ScreenshotKinds {
+			if sub[1] == kind {
+				m.Screenshots[kind] = append(m.Screenshots[kind], path)
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+	return true
+}
+
+// addTripleT records a file of a Triple-T listing or release notes directory
+func (m *LocaleMetadata) addTripleT(path, dir string, sub []string) bool {
+	if dir == "release-notes" {
+		// Release notes are per track, not per version: default.txt wins over the others
+		if len(sub) == 1 && (sub[0] == "default.txt" || m.Changelogs[0] == "") && filepath.Ext(sub[0]) == ".txt" {
+			m.Changelogs[0] = path
+			return true
+		}
+		return false
+	}
+	switch {
+	case len(sub) == 1 && sub[0] == "title.txt":
+		m.Name = readText(path)
+	case len(sub) == 1 && sub[0] == "short-description.txt":
+		m.Summary = readText(path)
+	case len(sub) == 1 && sub[0] == "full-description.txt":
+		m.Description = readText(path)
+	case len(sub) == 3 && sub[0] == "graphics" && sub[1] == "icon" && hasImageSuffix(path):
+		m.Icon = path
+	case len(sub) == 3 && sub[0] == "graphics" && sub[1] == "feature-graphic" && hasImageSuffix(path):
+		m.FeatureGraphic = path
+	case len(sub) == 3 && sub[0] == "graphics" && tripleTKinds[sub[1]] != "" && hasImageSuffix(path):
+		kind := tripleTKinds[sub[1]]
+		m.Screenshots[kind] = append(m.Screenshots[kind], path)
+	default:
+		return false
+	}
+	return true
+}
+
+// FindMetadata imports the store listings of a cloned repository, from the fastlane
+// (fastlane/metadata/android/<locale> or metadata/<locale>) and Triple-T (src/main/play)
+// layouts. Other images with screenshot in their path are used as phone screenshots
+// of the locales without any, unless rules include other paths.
+// Screenshots are in natural order, at most rules.Max of each kind.
+func FindMetadata(clonedRepoPath string, rules ScreenshotRules) (r RepoMetadata, err error) {
 	abs, err := filepath.Abs(clonedRepoPath)
 	if err != nil {
 		return
 	}
+	r = RepoMetadata{Locales: make(map[string]*LocaleMetadata)}
+	others := make(map[string][]string)
+	included := make(map[string][]string)
 
 	err = filepath.WalkDir(abs, func(path string, d fs.DirEntry, err error) error {
-		if err != nil || d.IsDir() {
+		if err != nil {
 			return err
 		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(abs, path)
+		if matchAny(rules.exclude, filepath.ToSlash(rel)) {
+			return nil
+		}
+		if len(rules.include) != 0 && hasImageSuffix(path) && matchAny(rules.include, filepath.ToSlash(rel)) {
+			locale, _, _ := localeOf(rel)
+			included[locale] = append(included[locale], path)
+			return nil
+		}
 
-		lp := strings.ToLower(path)
-
-		if strings.Contains(lp, "screenshot") && hasImageSuffix(path) {
-			r.Screenshots = append(r.Screenshots, path)
+		if dir, locale, sub, ok := tripleTOf(rel); ok && r.locale(locale).addTripleT(path, dir, sub) {
+			return nil
+		}
+		locale, sub, ok := localeOf(rel)
+		if ok && r.locale(locale).addFastlane(path, sub) {
 			return nil
 		}
 
+		if len(rules.include) == 0 && strings.Contains(strings.ToLower(path), "screenshot") && hasImageSuffix(path) {
+			others[locale] = append(others[locale], path)
+		}
 		return nil
 	})
 
+	for locale, screenshots := range others {
+		m := r.locale(locale)
+		if len(m.Screenshots[PhoneScreenshots]) == 0 {
+			m.Screenshots[PhoneScreenshots] = screenshots
+		}
+	}
+	for locale, screenshots := range included {
+		r.locale(locale).Screenshots[PhoneScreenshots] = screenshots
+	}
+	for _, m := range r.Locales {
+		for kind, screenshots := range m.Screenshots {
+			slices.SortFunc(screenshots, naturalCompare)
+			if rules.Max > 0 && len(screenshots) > rules.Max {
+				screenshots = screenshots[:rules.Max]
+			}
+			m.Screenshots[kind] = screenshots
+		}
+	}
+
 	return
 }
+
+// naturalCompare orders strings with their numbers by value, so 2.png comes before 10.png
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		ra, sa := utf8.DecodeRuneInString(a)
+		rb, sb := utf8.DecodeRuneInString(b)
+		if isDigit(ra) && isDigit(rb) {
+			na, nb := leadingDigits(a), leadingDigits(b)
+			// Compare the values without the leading zeros, the longest is the greatest
+			va, vb := strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if c := cmp.Compare(len(va), len(vb)); c != 0 {
+				return c
+			}
+			if c := strings.Compare(va, vb); c != 0 {
+				return c
+			}
+			a, b = a[len(na):], b[len(nb):]
+			continue
+		}
+		if ra != rb {
+			return cmp.Compare(ra, rb)
+		}
+		a, b = a[sa:], b[sb:]
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+func isDigit(r rune) bool { return r >= '0' && r <= '9' }
+
+func leadingDigits(s string) string {
+	i := strings.IndexFunc(s, func(r rune) bool { return !isDigit(r) })
+	if i < 0 {
+		return s
+	}
+	return s[:i]
+}
+
+// DedupeScreenshots drops the files with the same content as an earlier one
+// and returns the hashes of the others
+func DedupeScreenshots(paths []string) (unique []string, hashes []string, err error) {
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		var sum string
+		if sum, _, err = hashFile(p, "sha256"); err != nil {
+			return
+		}
+		if seen[sum] {
+			continue
+		}
+		seen[sum] = true
+		unique = append(unique, p)
+		hashes = append(hashes, sum)
+	}
+	return
+}
+
+// SameScreenshots tells whether dir holds files with exactly hashes, in natural order
+func SameScreenshots(dir string, hashes []string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != len(hashes) {
+		return false
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	slices.SortFunc(names, naturalCompare)
+	for i, name := range names {
+		sum, _, err := hashFile(filepath.Join(dir, name), "sha256")
+		if err != nil || sum != hashes[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package apps
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type appState struct {
+	app    *AppInfo
+	digest string
+}
+
+// releasesDigest identifies the app configuration together with the releases to ingest
+func releasesDigest(app *AppInfo, jobs []releaseJob) string {
+	h := sha256.New()
+	b, _ := json.Marshal(app)
+	h.Write(b)
+	for _, job := range jobs {
+		b, _ = json.Marshal(job.release)
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (l *AppLoader) statePath(app *AppInfo) string {
+	return filepath.Join(l.opts.StateDir, app.Name()+".sha256")
+}
+
+// unchanged reports whether the releases of app were already fully ingested in a previous run
+func (l *AppLoader) unchanged(app *AppInfo, jobs []releaseJob, digest, repoDir string) bool {
+	if l.opts.StateDir == "" {
+		return false
+	}
+	b, err := os.ReadFile(l.statePath(app))
+	if err != nil || strings.TrimSpace(string(b)) != digest {
+		return false
+	}
+	// APKs removed from the repo since then have to be downloaded again
+	for _, job := range jobs {
+		for _, asset := range FindAPKAssets(job.release, app.Assets) {
+			_, err = os.Stat(filepath.Join(repoDir, app.ReleaseFilename(job.release.TagName, asset)))
+			if errors.Is(err, os.ErrNotExist) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SaveState records the releases ingested by All so the next run can skip them
+func (l *AppLoader) SaveState() (err error) {
+	if l.opts.StateDir == "" || l.opts.Plan.DryRun() {
+		return
+	}
+	if err = os.MkdirAll(l.opts.StateDir, os.ModePerm); err != nil {
+		return
+	}
+	for _, s := range l.pending {
+		if err = os.WriteFile(l.statePath(s.app), []byte(s.digest+"\n"), 0o644); err != nil {
+			return
+		}
+	}
+	l.pending = nil
+	return
+}
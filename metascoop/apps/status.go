@@ -0,0 +1,184 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"gopkg.in/yaml.v3"
+)
+
+// prApkPattern matches the <app>_pr_<n>_<sha>.apk files of PR builds
+var prApkPattern = regexp.MustCompile(`^(.+)_pr_(\d+)_([0-9a-f]+)\.apk$`)
+
+// ParsePRApk splits the file name of a PR build
+func ParsePRApk(apkName string) (app string, number int, sha string, ok bool) {
+	m := prApkPattern.FindStringSubmatch(apkName)
+	if m == nil {
+		return
+	}
+	number, err := strconv.Atoi(m[2])
+	if err != nil {
+		return
+	}
+	return m[1], number, m[3], true
+}
+
+// ownerOf returns the key of the app publishing apkName, or "" when no app does
+func (a *AppFile) ownerOf(apkName string) (owner string) {
+	for key := range a.Apps {
+		// The longest key wins so that app_rc isn't taken for app
+		if strings.HasPrefix(apkName, key+"_") && len(key) > len(owner) {
+			owner = key
+		}
+	}
+	return
+}
+
+type PackageStatus struct {
+	PackageName string `json:"packageName"`
+	// CurrentVersion is the one set in the metadata file
+	CurrentVersion string `json:"currentVersion"`
+	Icon           bool   `json:"icon"`
+	Screenshots    int    `json:"screenshots"`
+}
+
+type AppStatus struct {
+	App string `json:"app"`
+	// Versions are the released versions in the repo index, oldest first
+	Versions []string        `json:"versions"`
+	Packages []PackageStatus `json:"packages"`
+	// Upstream is the newest release matching the app rules
+	Upstream string   `json:"upstream,omitempty"`
+	PRs      []int    `json:"prs,omitempty"`
+	Badge    string   `json:"badge,omitempty"`
+	Issues   []string `json:"issues,omitempty"`
+}
+
+// Latest returns the newest version in the repo
+func (s *AppStatus) Latest() string {
+	if len(s.Versions) == 0 {
+		return ""
+	}
+	return s.Versions[len(s.Versions)-1]
+}
+
+type Status struct {
+	Apps []*AppStatus `json:"apps"`
+	// Orphans are the packages of the repo that no app publishes
+	Orphans []string `json:"orphans,omitempty"`
+}
+
+// Status compares apps.yaml, the repo in repoDir and, unless offline, the upstream releases
+func (l *AppLoader) Status(repoDir string, offline bool) (status *Status, err error) {
+	index, err := LoadIndex(repoDir)
+	if err != nil {
+		return
+	}
+	fdroidDir := filepath.Dir(repoDir)
+	badges := make(map[string]string)
+	if b, rerr := os.ReadFile(filepath.Join(filepath.Dir(fdroidDir), "badges.yaml")); rerr == nil {
+		_ = yaml.Unmarshal(b, &badges)
+	}
+
+	status = &Status{}
+	byApp := make(map[string]*AppStatus)
+	keys := make([]string, 0, len(l.apps.Apps))
+	for key := range l.apps.Apps {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		s := &AppStatus{App: key, Badge: badges[key]}
+		byApp[key] = s
+		status.Apps = append(status.Apps, s)
+	}
+
+	names := make([]string, 0, len(index.Packages))
+	for name := range index.Packages {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		pkgs := index.Packages[name]
+		if len(pkgs) == 0 {
+			continue
+		}
+		s, ok := byApp[l.apps.ownerOf(pkgs[0].ApkName)]
+		if !ok {
+			status.Orphans = append(status.Orphans, name)
+			continue
+		}
+		for _, p := range pkgs {
+			if _, number, _, ok := ParsePRApk(p.ApkName); ok {
+				if !slices.Contains(s.PRs, number) {
+					s.PRs = append(s.PRs, number)
+				}
+			} else if !slices.Contains(s.Versions, p.VersionName) {
+				s.Versions = append(s.Versions, p.VersionName)
+			}
+		}
+
+		ps := PackageStatus{PackageName: name}
+		if meta, merr := ReadMetaFile(filepath.Join(fdroidDir, "metadata", name+".yml")); merr == nil {
+			ps.CurrentVersion = fmt.Sprint(meta["CurrentVersion"])
+		} else {
+			s.Issues = append(s.Issues, fmt.Sprintf("%s has no metadata file", name))
+		}
+		localeDir := filepath.Join(repoDir, name, DefaultLocale)
+		if _, serr := os.Stat(filepath.Join(localeDir, "icon.png")); serr == nil {
+			ps.Icon = true
+		} else {
+			s.Issues = append(s.Issues, fmt.Sprintf("%s has no icon", name))
+		}
+		screenshots, _ := os.ReadDir(filepath.Join(localeDir, "phoneScreenshots"))
+		if ps.Screenshots = len(screenshots); ps.Screenshots == 0 {
+			s.Issues = append(s.Issues, fmt.Sprintf("%s has no screenshots", name))
+		}
+		if latest, ok := index.FindLatestPackage(name); ok && ps.CurrentVersion != latest.VersionName {
+			s.Issues = append(s.Issues, fmt.Sprintf("%s CurrentVersion is %q instead of %q", name, ps.CurrentVersion, latest.VersionName))
+		}
+		s.Packages = append(s.Packages, ps)
+	}
+
+	for _, key := range keys {
+		s := byApp[key]
+		slices.Sort(s.PRs)
+		slices.SortFunc(s.Versions, compareVersions)
+		if offline || l.apps.Apps[key].Releases.Channel == ChannelNone {
+			continue
+		}
+		jobs, lerr := l.listReleases(context.Background(), l.apps.Apps[key])
+		if lerr != nil {
+			slog.Warn("Listing releases", "app", key, "err", lerr)
+			s.Issues = append(s.Issues, "upstream releases couldn't be listed")
+			continue
+		}
+		if len(jobs) == 0 {
+			continue
+		}
+		s.Upstream = jobs[len(jobs)-1].release.TagName
+		if s.Latest() == "" || compareVersions(s.Latest(), s.Upstream) < 0 {
+			s.Issues = append(s.Issues, fmt.Sprintf("behind upstream %s", s.Upstream))
+		}
+	}
+	return
+}
+
+// compareVersions orders versions, falling back to a string comparison when one isn't a version
+func compareVersions(a, b string) int {
+	va, erra := version.NewVersion(a)
+	vb, errb := version.NewVersion(b)
+	if erra != nil || errb != nil {
+		return strings.Compare(a, b)
+	}
+	return va.Compare(vb)
+}
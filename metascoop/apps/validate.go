@@ -0,0 +1,140 @@
+package apps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"metascoop/apk"
+	"metascoop/file"
+	"metascoop/logging"
+	"metascoop/signer"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SignerMismatchError is returned when an APK isn't signed by one of the allowed signers
+type SignerMismatchError struct {
+	App      string   `json:"app"`
+	File     string   `json:"file"`
+	Expected []string `json:"expected"`
+	Actual   []string `json:"actual"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+func (e *SignerMismatchError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("APK %q for %q has no valid signature: %s", e.File, e.App, e.Reason)
+	}
+	return fmt.Sprintf("APK %q for %q is signed by %v instead of one of %v", e.File, e.App, e.Actual, e.Expected)
+}
+
+// normalizeFingerprint accepts fingerprints written with colons or in uppercase
+func normalizeFingerprint(fp string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fp), ":", ""))
+}
+
+// validateAPK checks the APK at apkPath against the app before it is published.
+// When tag is set and the app asks for it, the versionName must match it.
+func (app *AppInfo) validateAPK(ctx context.Context, apkPath string, tag string) (err error) {
+	info, err := apk.ReadInfo(apkPath)
+	if err != nil {
+		return fmt.Errorf("invalid APK for %q: %w", app.Name(), err)
+	}
+	slog.InfoContext(ctx, "APK info", "package", info.PackageName, "versionCode", info.VersionCode, "versionName", info.VersionName, "minSdk", info.MinSdkVersion, "targetSdk", info.TargetSdkVersion, "abis", info.NativeCode)
+
+	if app.PackageName != "" {
+		ok, merr := path.Match(app.PackageName, info.PackageName)
+		if merr != nil {
+			return fmt.Errorf("invalid package_name pattern %q for %q: %w", app.PackageName, app.Name(), merr)
+		}
+		if !ok {
+			return fmt.Errorf("APK package %q doesn't match %q for %q", info.PackageName, app.PackageName, app.Name())
+		}
+	}
+
+	if info.VersionCode <= 0 {
+		return fmt.Errorf("APK %q has an invalid versionCode %d", info.PackageName, info.VersionCode)
+	}
+
+	if app.MatchTag && tag != "" && strings.TrimPrefix(tag, "v") != strings.TrimPrefix(info.VersionName, "v") {
+		return fmt.Errorf("APK versionName %q doesn't match release %q for %q", info.VersionName, tag, app.Name())
+	}
+
+	if info.Debuggable && !app.Debug {
+		return fmt.Errorf("APK %q is debuggable but %q isn't a debug app", info.PackageName, app.Name())
+	}
+
+	return app.checkSigners(ctx, apkPath)
+}
+
+// checkSigners verifies the APK signing block against the allowed signers, if any
+func (app *AppInfo) checkSigners(ctx context.Context, apkPath string) error {
+	if len(app.AllowedSigners) == 0 {
+		return nil
+	}
+	mismatch := &SignerMismatchError{App: app.Name(), File: filepath.Base(apkPath)}
+	for _, fp := range app.AllowedSigners {
+		mismatch.Expected = append(mismatch.Expected, normalizeFingerprint(fp))
+	}
+
+	certs, err := signer.VerifyFile(apkPath)
+	if err != nil {
+		mismatch.Reason = err.Error()
+		return mismatch
+	}
+	for _, cert := range certs {
+		mismatch.Actual = append(mismatch.Actual, signer.Fingerprint(cert))
+	}
+	for _, fp := range mismatch.Actual {
+		if !slices.Contains(mismatch.Expected, fp) {
+			return mismatch
+		}
+	}
+	slog.InfoContext(ctx, "APK signed by an allowed signer", "signers", mismatch.Actual)
+	return nil
+}
+
+// validator returns the check run on downloaded APKs, logging to ctx and moving the ones
+// with a bad signer to the quarantine
+func (l *AppLoader) validator(ctx context.Context, app *AppInfo, tag string) func(path string) error {
+	return func(apkPath string) error {
+		err := app.validateAPK(ctx, apkPath, tag)
+		mismatch, ok := err.(*SignerMismatchError)
+		if !ok || l.opts.QuarantineDir == "" {
+			return err
+		}
+		mismatch.File = strings.TrimSuffix(mismatch.File, ".tmp")
+		if qerr := quarantine(ctx, l.opts.QuarantineDir, apkPath, mismatch); qerr != nil {
+			slog.ErrorContext(ctx, "Quarantining APK", logging.KeyFile, apkPath, "err", qerr)
+		}
+		return err
+	}
+}
+
+func quarantine(ctx context.Context, dir string, apkPath string, mismatch *SignerMismatchError) (err error) {
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return
+	}
+	name := fmt.Sprintf("%s_%d", mismatch.File, time.Now().Unix())
+	target := filepath.Join(dir, name)
+	err = file.Move(apkPath, target)
+	if err != nil {
+		return
+	}
+	report, err := json.MarshalIndent(mismatch, "", "  ")
+	if err != nil {
+		return
+	}
+	err = os.WriteFile(target+".json", report, 0o644)
+	if err != nil {
+		return
+	}
+	slog.WarnContext(ctx, "Quarantined APK", logging.KeyFile, mismatch.File, "to", target)
+	return
+}
@@ -0,0 +1,120 @@
+package apps
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Problem is an inconsistency between the index and the files on disk
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+func newHash(hashType string) (hash.Hash, error) {
+	switch strings.ToLower(hashType) {
+	case "sha256", "":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "md5":
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported hash type %q", hashType)
+}
+
+func hashFile(path string, hashType string) (sum string, size int64, err error) {
+	h, err := newHash(hashType)
+	if err != nil {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	size, err = io.Copy(h, f)
+	sum = hex.EncodeToString(h.Sum(nil))
+	return
+}
+
+// Verify checks the index of repoDir against the APKs on disk and the metadata directory next to it
+func Verify(repoDir string) (problems []Problem, err error) {
+	index, err := LoadIndex(repoDir)
+	if err != nil {
+		return
+	}
+
+	indexed := make(map[string]bool)
+	for _, pkgs := range index.Packages {
+		for _, p := range pkgs {
+			indexed[p.ApkName] = true
+			path := filepath.Join(repoDir, p.ApkName)
+			sum, size, herr := hashFile(path, p.HashType)
+			switch {
+			case os.IsNotExist(herr):
+				problems = append(problems, Problem{path, "indexed but missing"})
+			case herr != nil:
+				problems = append(problems, Problem{path, herr.Error()})
+			case !strings.EqualFold(sum, p.Hash):
+				problems = append(problems, Problem{path, fmt.Sprintf("%s is %s instead of %s", p.HashType, sum, p.Hash)})
+			case size != int64(p.Size):
+				problems = append(problems, Problem{path, fmt.Sprintf("size is %d instead of %d", size, p.Size)})
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(repoDir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		path := filepath.Join(repoDir, e.Name())
+		switch filepath.Ext(e.Name()) {
+		case ".apk":
+			if !indexed[e.Name()] {
+				problems = append(problems, Problem{path, "present but not indexed"})
+			}
+		case ".tmp", ".idsig":
+			problems = append(problems, Problem{path, "leftover from a download or signing"})
+		}
+	}
+
+	metadataDir := filepath.Join(filepath.Dir(repoDir), "metadata")
+	entries, err = os.ReadDir(metadataDir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, serr := os.Stat(filepath.Join(metadataDir, e.Name()+".yml")); os.IsNotExist(serr) {
+			problems = append(problems, Problem{filepath.Join(metadataDir, e.Name()), "metadata directory without a .yml file"})
+		}
+	}
+
+	slices.SortFunc(problems, func(a, b Problem) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return
+}
@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"metascoop/apps"
+	"metascoop/logging"
+)
+
+type LintCmd struct{}
+
+func (c *LintCmd) Run(g *Globals) error {
+	errs, err := apps.Lint(g.AppFile)
+	if err != nil {
+		return err
+	}
+	for _, e := range errs {
+		slog.Error(e.Message, logging.KeyFile, g.AppFile, logging.KeyLine, e.Line, logging.KeyColumn, e.Column)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("found %d problems in %q", len(errs), g.AppFile)
+	}
+	return nil
+}
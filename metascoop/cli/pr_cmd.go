@@ -1,35 +1,58 @@
 package cli
 
 import (
+	"cmp"
 	"fmt"
-	"log"
+	"log/slog"
 	"metascoop/apps"
 	"metascoop/file"
+	"metascoop/logging"
 	"metascoop/md"
+	"metascoop/plan"
+	"metascoop/signer"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 )
 
 type PrCmd struct {
-	App    string      `help:"app" required:""`
-	Number int         `help:"Pr number" required:""`
+	App    string      `help:"app, required by add and delete"`
+	Number int         `help:"Pr number, required by add and delete"`
 	Add    PrAddCmd    `cmd:"" help:"Add apk from a PR"`
-	Delete PrDeleteCmd `cmd:"" help:"Add apk from a PR"`
+	Delete PrDeleteCmd `cmd:"" help:"Delete the apks of a PR"`
+	Gc     PrGcCmd     `cmd:"" help:"Delete the apks of closed or stale PRs"`
+}
+
+// check validates the flags needed by the commands working on a single PR
+func (c *PrCmd) check() error {
+	if c.App == "" || c.Number == 0 {
+		return fmt.Errorf("--app and --number are required")
+	}
+	return nil
 }
 
 type PrAddCmd struct {
 	ArtifactID int    `arg:"" help:"Artifact id"`
 	SHA        string `arg:"" help:"SHA ref"`
+	V1         bool   `help:"Also sign with the v1 (JAR) scheme" default:"false"`
+	Commits    bool   `help:"List the commits since the base of the PR in the changelog" default:"false"`
 }
 
 type PrDeleteCmd struct {
 }
 
+type PrGcCmd struct {
+	TTL time.Duration `help:"Also delete the PRs whose last build is older than this, whatever their state" name:"ttl" default:"0"`
+}
+
 func (a *PrAddCmd) Run(g *Globals, c *PrCmd) (err error) {
+	if err = c.check(); err != nil {
+		return
+	}
 	var appName string
-	appName, err = g.loader.FromPR(g.RepoDir, c.App, c.Number, a.ArtifactID, a.SHA)
+	appName, err = g.loader.FromPR(g.RepoDir, c.App, c.Number, a.ArtifactID, a.SHA, a.Commits)
 	if err != nil {
 		return
 	}
@@ -41,106 +64,230 @@ func (a *PrAddCmd) Run(g *Globals, c *PrCmd) (err error) {
 	if err != nil {
 		return err
 	}
-	jks := fmt.Sprintf("%s.jks", strings.TrimSuffix(config.Keystore, ".p12"))
-	cmd := exec.Command("keytool", "-importkeystore", "-srckeystore", config.Keystore, "-srcstoretype", "pkcs12", "-srckeypass", config.Keypass, "-srcstorepass", config.Keystorepass, "-srcalias", config.Alias, "-destkeystore", jks, "-destkeypass", config.Keypass, "-deststorepass", config.Keystorepass, "-destalias", config.Alias)
-	cmd.Dir = path
-	_ = cmd.Run()
-
-	tools := os.ExpandEnv(filepath.Join(config.SdkPath, "build-tools", g.appFile.BuildToolsVersion, "apksigner"))
-	cmd = exec.Command(tools, "sign", "--ks", filepath.Join(filepath.Dir(g.RepoDir), jks), "--ks-key-alias", config.Alias, "--ks-pass", fmt.Sprintf("pass:%s", config.Keystorepass), "--ks-pass", fmt.Sprintf("pass:%s", config.Keypass), "--out", out, app)
-	if err := cmd.Run(); err != nil {
+	key, err := signer.LoadPKCS12(config.KeystorePath(path), config.Alias, config.Keystorepass, config.Keypass)
+	if err != nil {
+		return err
+	}
+	if g.plan.DryRun() {
+		g.plan.Record(plan.Action{Op: plan.OpSign, Path: app, Detail: "with " + config.Alias})
+		return g.updateAndPull()
+	}
+	err = signer.SignFile(app, out, key, signer.Options{V1: a.V1, V2: true, V3: true})
+	if err != nil {
+		_ = os.Remove(out)
+		return fmt.Errorf("signing %q: %w", app, err)
+	}
+	if err = file.Move(out, app); err != nil {
 		return err
 	}
-	_ = file.Move(out, app)
-	_ = os.Remove(fmt.Sprintf("%s.idsig", out))
+	slog.Info("Signed", "apk", app, "alias", config.Alias)
 	err = g.updateAndPull()
 	return
 }
 
 func (d *PrDeleteCmd) Run(g *Globals, c *PrCmd) error {
-	prefix := fmt.Sprintf("%s_pr_%d", c.App, c.Number)
+	if err := c.check(); err != nil {
+		return err
+	}
+	if err := g.deletePRs([]prBuild{{app: c.App, number: c.Number}}); err != nil {
+		return err
+	}
+	return g.refreshRepo()
+}
+
+// prBuild identifies the builds of a PR of an app
+type prBuild struct {
+	app    string
+	number int
+	added  int64
+}
+
+func (b prBuild) prefix() string {
+	return fmt.Sprintf("%s_pr_%d_", b.app, b.number)
+}
 
-	fdroidIndexFilePath := filepath.Join(g.RepoDir, "index-v1.json")
-	fdroidIndex, err := apps.ReadIndex(fdroidIndexFilePath)
+func (gc *PrGcCmd) Run(g *Globals) error {
+	fdroidIndex, err := apps.LoadIndex(g.RepoDir)
 	if err != nil {
 		return err
 	}
+
+	var builds []*prBuild
+	seen := make(map[string]*prBuild)
 	for _, packages := range fdroidIndex.Packages {
-		if strings.HasPrefix(packages[0].ApkName, c.App) {
-			packageName := packages[0].PackageName
-			toRemovePaths := make([]string, 0)
-			versionCodes := make(map[int]struct{})
-
-			for _, p := range fdroidIndex.Packages[packageName] {
-				if strings.HasPrefix(p.ApkName, prefix) {
-					file := filepath.Join(filepath.Dir(g.RepoDir), "metadata", p.PackageName, "en-US", "changelogs", fmt.Sprintf("%d.txt", p.VersionCode))
-					toRemovePaths = append(toRemovePaths, file)
-					toRemovePaths = append(toRemovePaths, filepath.Join(g.RepoDir, p.ApkName))
-					versionCodes[p.VersionCode] = struct{}{}
-				}
+		for _, p := range packages {
+			app, number, _, ok := apps.ParsePRApk(p.ApkName)
+			if !ok {
+				continue
+			}
+			key := fmt.Sprintf("%s_pr_%d", app, number)
+			b, ok := seen[key]
+			if !ok {
+				b = &prBuild{app: app, number: number}
+				seen[key] = b
+				builds = append(builds, b)
 			}
+			b.added = max(b.added, p.Added)
+		}
+	}
+	slices.SortFunc(builds, func(a, b *prBuild) int {
+		return cmp.Or(cmp.Compare(a.app, b.app), cmp.Compare(a.number, b.number))
+	})
 
-			if len(toRemovePaths) == 0 {
-				log.Printf("No files found for %d\n", c.Number)
+	var removed []prBuild
+	for _, b := range builds {
+		reason := ""
+		if gc.TTL > 0 && time.Since(time.UnixMilli(b.added)) > gc.TTL {
+			reason = fmt.Sprintf("its last build is older than %s", gc.TTL)
+		} else {
+			pr, err := g.loader.PullRequest(b.app, b.number)
+			if err != nil {
+				slog.Warn("Looking up PR", "app", b.app, "pr", b.number, "err", err)
 				continue
 			}
-
-			for _, path := range toRemovePaths {
-				_ = os.Remove(path)
+			if pr.Open() {
+				slog.Info("PR is still open", "app", b.app, "pr", b.number)
+				continue
 			}
-
-			if len(fdroidIndex.Packages[packageName]) == len(toRemovePaths)/2 {
-				_ = os.RemoveAll(filepath.Join(filepath.Dir(g.RepoDir), "metadata", packageName))
-				_ = os.RemoveAll(filepath.Join(g.RepoDir, packageName))
+			reason = "it is " + pr.State
+			if pr.Merged {
+				reason = "it was merged"
 			}
+		}
+		slog.Info("Removing PR build", "app", b.app, "pr", b.number, "reason", reason)
+		removed = append(removed, *b)
+	}
 
-			if err := runFdroidUpdate(g.RepoDir); err != nil {
-				return err
-			}
+	if len(removed) == 0 {
+		slog.Info("No PR build to remove")
+		return nil
+	}
+	if err := g.deletePRs(removed); err != nil {
+		return err
+	}
+	return g.refreshRepo()
+}
 
-			fdroidIndex, _ = apps.ReadIndex(fdroidIndexFilePath)
-			if lastest, ok := fdroidIndex.FindLatestPackage(packageName); ok {
-				path := filepath.Join(filepath.Dir(g.RepoDir), "metadata", fmt.Sprintf("%s.yml", packageName))
+// deletePRs removes the builds of the PRs, their changelogs and Builds entries.
+// The index is regenerated by the caller.
+func (g *Globals) deletePRs(prs []prBuild) error {
+	fdroidIndex, err := apps.LoadIndex(g.RepoDir)
+	if err != nil {
+		return err
+	}
+	metadataDir := filepath.Join(filepath.Dir(g.RepoDir), "metadata")
 
-				meta, err := apps.ReadMetaFile(path)
-				if err != nil {
-					log.Printf("Reading meta file %q: %s", path, err.Error())
-					return err
-				}
+	found := make(map[prBuild]bool)
+	// removed holds the versionCodes removed from each package
+	removed := make(map[string]map[int]struct{})
+	for packageName, packages := range fdroidIndex.Packages {
+		for _, p := range packages {
+			i := slices.IndexFunc(prs, func(b prBuild) bool { return strings.HasPrefix(p.ApkName, b.prefix()) })
+			if i < 0 {
+				continue
+			}
+			found[prs[i]] = true
+			if removed[packageName] == nil {
+				removed[packageName] = make(map[int]struct{})
+			}
+			removed[packageName][p.VersionCode] = struct{}{}
 
-				builds := make([]map[string]interface{}, 0)
-				for _, b := range meta["Builds"].([]interface{}) {
-					build := b.(map[string]interface{})
-					versionCode := build["versionCode"].(int)
-					if _, ok := versionCodes[versionCode]; !ok {
-						builds = append(builds, build)
-					}
-				}
+			changelogs, _ := filepath.Glob(filepath.Join(metadataDir, packageName, "*", "changelogs", fmt.Sprintf("%d.txt", p.VersionCode)))
+			for _, path := range changelogs {
+				_ = g.plan.Remove(path)
+			}
+			_ = g.plan.Remove(filepath.Join(g.RepoDir, p.ApkName))
+		}
+	}
+	for _, b := range prs {
+		if !found[b] {
+			slog.Warn("No files found for PR", "app", b.app, "pr", b.number)
+		}
+	}
 
-				sortBuilds(builds)
+	packageNames := make([]string, 0, len(removed))
+	for packageName := range removed {
+		packageNames = append(packageNames, packageName)
+	}
+	slices.Sort(packageNames)
+	for _, packageName := range packageNames {
+		versionCodes := removed[packageName]
+		// The index is updated by hand, fdroid runs once every build is removed
+		var kept []apps.PackageInfo
+		for _, p := range fdroidIndex.Packages[packageName] {
+			if _, ok := versionCodes[p.VersionCode]; !ok {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			delete(fdroidIndex.Packages, packageName)
+			_ = g.plan.RemoveAll(filepath.Join(metadataDir, packageName))
+			_ = g.plan.Remove(filepath.Join(metadataDir, fmt.Sprintf("%s.yml", packageName)))
+			_ = g.plan.RemoveAll(filepath.Join(g.RepoDir, packageName))
+			continue
+		}
+		fdroidIndex.Packages[packageName] = kept
 
-				meta["CurrentVersion"] = lastest.VersionName
-				meta["CurrentVersionCode"] = lastest.VersionCode
-				meta["Builds"] = builds
+		latest, _ := fdroidIndex.FindLatestPackage(packageName)
+		path := filepath.Join(metadataDir, fmt.Sprintf("%s.yml", packageName))
+		meta, err := apps.ReadMetaFile(path)
+		if err != nil {
+			slog.Warn("Reading meta file", logging.KeyFile, path, "err", err)
+			return err
+		}
 
-				err = apps.WriteMetaFile(path, meta)
+		builds, ok := keptBuilds(meta["Builds"], versionCodes)
+		if !ok {
+			// The builds are left as they are rather than guessed
+			slog.Warn("Malformed Builds, not updating them", logging.KeyFile, path)
+			continue
+		}
+		sortBuilds(builds)
 
-				if err != nil {
-					log.Printf("Writing meta file %q: %s", path, err.Error())
-					return err
-				}
+		meta["CurrentVersion"] = latest.VersionName
+		meta["CurrentVersionCode"] = latest.VersionCode
+		meta["Builds"] = builds
 
-			}
+		if err = apps.WriteMetaFile(g.plan, path, meta); err != nil {
+			slog.Error("Writing meta file", logging.KeyFile, path, "err", err)
+			return err
+		}
+	}
+	return nil
+}
 
+// keptBuilds returns the Builds entries of a metadata file whose versionCode isn't removed,
+// ok is false when they aren't a list of builds with a versionCode
+func keptBuilds(v interface{}, removed map[int]struct{}) (builds []map[string]interface{}, ok bool) {
+	list, ok := v.([]interface{})
+	if !ok && v != nil {
+		return
+	}
+	builds = make([]map[string]interface{}, 0, len(list))
+	for _, b := range list {
+		build, ok := b.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		versionCode, ok := build["versionCode"].(int)
+		if !ok {
+			return nil, false
+		}
+		if _, ok := removed[versionCode]; !ok {
+			builds = append(builds, build)
 		}
 	}
+	return builds, true
+}
 
-	if err := runFdroidUpdate(g.RepoDir); err != nil {
+// refreshRepo regenerates the index, badges and README after builds were removed
+func (g *Globals) refreshRepo() error {
+	if err := g.runFdroidUpdate(); err != nil {
 		return err
 	}
-	if err := apps.GenerateBadges(g.AppFile, g.RepoDir); err != nil {
+	if err := apps.GenerateBadges(g.plan, g.AppFile, g.RepoDir); err != nil {
 		return err
 	}
 
-	return md.RegenerateReadme(g.RepoDir)
+	return md.RegenerateReadme(g.plan, g.RepoDir, g.ReadmeLocale)
 }
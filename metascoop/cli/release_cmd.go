@@ -1,19 +1,25 @@
 package cli
 
+import "errors"
+
 type ReleaseCmd struct {
 	App     string `arg:"" help:"app" optional:""`
 	Version string `arg:"" help:"Release version" optional:""`
 }
 
 func (c *ReleaseCmd) Run(g *Globals) (err error) {
+	var loadErr error
 	if c.App == "" || c.Version == "" {
-		err = g.loader.All(g.RepoDir)
-	} else {
-		err = g.loader.FromRelease(g.RepoDir, c.App, c.Version)
-	}
-	if err != nil {
+		// The apps that failed are reported once the others are published
+		loadErr = g.loader.All(g.RepoDir)
+	} else if err = g.loader.FromRelease(g.RepoDir, c.App, c.Version); err != nil {
 		return
 	}
-	err = g.updateAndPull()
-	return
+	if err = g.updateAndPull(); err != nil {
+		return errors.Join(loadErr, err)
+	}
+	if err = g.loader.SaveState(); err != nil {
+		return errors.Join(loadErr, err)
+	}
+	return loadErr
 }
@@ -2,19 +2,23 @@ package cli
 
 import (
 	"cmp"
-	"context"
 	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
+	"metascoop/apk"
 	"metascoop/apps"
-	"metascoop/file"
+	"metascoop/forge"
 	"metascoop/git"
+	"metascoop/images"
+	"metascoop/logging"
 	"metascoop/md"
+	"metascoop/plan"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/alecthomas/kong"
@@ -23,13 +27,24 @@ import (
 )
 
 type Globals struct {
-	githubClient *github.Client
-	appFile      *apps.AppFile
-	loader       *apps.AppLoader
-	AppFile      string `help:"Path to apps.yaml file" type:"path" short:"a" default:"apps.yaml"`
-	RepoDir      string `help:"path to fdroid \"repo\" directory" type:"path" short:"r" default:"fdroid/repo"`
-	AccessToken  string `help:"GitHub personal access token" short:"t"`
-	Debug        bool   `help:"Debug mode won't run the fdroid command" short:"d" default:"false"`
+	githubClient  *github.Client
+	forges        *forge.Registry
+	appFile       *apps.AppFile
+	loader        *apps.AppLoader
+	plan          *plan.Plan
+	clones        *git.Cache
+	AppFile       string            `help:"Path to apps.yaml file" type:"path" short:"a" default:"apps.yaml"`
+	RepoDir       string            `help:"path to fdroid \"repo\" directory" type:"path" short:"r" default:"fdroid/repo"`
+	AccessToken   string            `help:"GitHub personal access token" short:"t"`
+	ForgeTokens   map[string]string `help:"Access tokens of other forges as host=token" placeholder:"HOST=TOKEN"`
+	Debug         bool              `help:"Debug mode won't run the fdroid command" short:"d" default:"false"`
+	QuarantineDir string            `help:"Directory receiving the APKs whose signer isn't allowed" type:"path" default:"fdroid/quarantine"`
+	Concurrency   int               `help:"Number of concurrent forge listings and downloads" short:"j" default:"4"`
+	CacheDir      string            `help:"Directory caching forge responses and git clones between runs, disabled when empty" type:"path"`
+	DryRun        bool              `help:"Print the planned changes instead of applying them" default:"false"`
+	PlanFormat    string            `help:"Format of the dry run plan" enum:"text,json" default:"text"`
+	ReadmeLocale  string            `help:"Locale of the names and summaries of the README apps table" default:"en-US"`
+	LogFormat     string            `help:"Format of the logs, auto detects GitHub Actions and GitLab CI" enum:"auto,github,gitlab,plain,json" default:"auto"`
 }
 
 type CLI struct {
@@ -38,49 +53,93 @@ type CLI struct {
 	Release ReleaseCmd `cmd:"" help:"Get releases"`
 	Pr      PrCmd      `cmd:"" help:"Get apk from a PR"`
 	Badges  BadgesCmd  `cmd:"" help:"Generate badges"`
+	Status  StatusCmd  `cmd:"" help:"Show the drift between apps.yaml, upstream and the repo"`
+	Verify  VerifyCmd  `cmd:"" help:"Check the repo index against the files on disk"`
+	Lint    LintCmd    `cmd:"" help:"Check the apps.yaml file"`
 }
 
 type BadgesCmd struct{}
 
-func (g *Globals) AfterApply() error {
+func (g *Globals) AfterApply(ctx *kong.Context) error {
+	if err := logging.Setup(g.LogFormat, os.Stderr); err != nil {
+		return err
+	}
+	// lint reports the problems of the app file itself
+	if ctx.Command() == "lint" {
+		return nil
+	}
+	g.plan = plan.New(g.DryRun)
 	appFile, err := apps.ParseAppFile(g.AppFile)
 	if err != nil {
 		return err
 	}
 	g.appFile = appFile
-	var authenticatedClient *http.Client = nil
+	// Every forge call goes through the retrying transport
+	httpClient := &http.Client{Transport: g.transport(http.DefaultTransport)}
+	authenticatedClient := httpClient
 	if g.AccessToken != "" {
-		ctx := context.Background()
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: g.AccessToken},
 		)
-		authenticatedClient = oauth2.NewClient(ctx, ts)
+		// The token is set above the cache, which keys the responses by credentials
+		authenticatedClient = &http.Client{Transport: &oauth2.Transport{Source: ts, Base: g.transport(http.DefaultTransport)}}
 	}
 
 	g.githubClient = github.NewClient(authenticatedClient)
-	g.loader = g.appFile.NewLoader(g.githubClient)
+	g.forges = forge.NewRegistry(forge.Options{GitHubClient: g.githubClient, HTTPClient: httpClient, Tokens: g.ForgeTokens})
+	g.clones = &git.Cache{}
+	opts := apps.LoaderOptions{QuarantineDir: g.QuarantineDir, Concurrency: g.Concurrency, Plan: g.plan}
+	if g.CacheDir != "" {
+		opts.StateDir = filepath.Join(g.CacheDir, "releases")
+		g.clones.Dir = filepath.Join(g.CacheDir, "git")
+	}
+	g.loader = g.appFile.NewLoader(g.forges, opts)
 	return nil
 }
 
+// transport wraps base with retries and, when enabled, the conditional request cache.
+// Credentials must be set on the requests above it for the cache to tell them apart.
+func (g *Globals) transport(base http.RoundTripper) http.RoundTripper {
+	t := http.RoundTripper(forge.NewRetryTransport(base))
+	if g.CacheDir != "" {
+		t = forge.NewCacheTransport(filepath.Join(g.CacheDir, "http"), t)
+	}
+	return t
+}
+
 func (c *BadgesCmd) Run(g *Globals) error {
-	return apps.GenerateBadges(g.AppFile, g.RepoDir)
+	return apps.GenerateBadges(g.plan, g.AppFile, g.RepoDir)
 }
 
 func (g *Globals) updateAndPull() error {
 	if !g.Debug {
-		if err := runFdroidUpdate(g.RepoDir); err != nil {
+		if err := g.runFdroidUpdate(); err != nil {
 			return err
 		}
 	}
-	fdroidIndexFilePath := filepath.Join(g.RepoDir, "index-v1.json")
-	fdroidIndex, err := apps.ReadIndex(fdroidIndexFilePath)
+	fdroidIndex, err := apps.LoadIndex(g.RepoDir)
 	if err != nil {
-		return fmt.Errorf("reading f-droid repo index: %s\n::endgroup::\n", err.Error())
+		return fmt.Errorf("reading f-droid repo index: %w", err)
 	}
 	apkInfoMap := g.appFile.Apks()
 	var toRemovePaths []string
 
 	walkPath := filepath.Join(filepath.Dir(g.RepoDir), "metadata")
+
+	// The loader only keeps the ingested apps, the policies come from the whole file
+	appFile, err := apps.ParseAppFile(g.AppFile)
+	if err != nil {
+		return err
+	}
+	pruned, err := appFile.Prune(g.plan, fdroidIndex, g.RepoDir, walkPath)
+	if err != nil {
+		return err
+	}
+	slog.Info("Pruned APKs", "count", len(pruned))
+	prunedPackages := make(map[string]bool)
+	for _, p := range pruned {
+		prunedPackages[p.PackageName] = true
+	}
 	err = filepath.WalkDir(walkPath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".yml") {
 			return err
@@ -88,35 +147,65 @@ func (g *Globals) updateAndPull() error {
 
 		pkgname := strings.TrimSuffix(filepath.Base(path), ".yml")
 
-		fmt.Printf("::group::%s\n", pkgname)
-
 		return func() error {
-			defer fmt.Println("::endgroup::")
-			log.Printf("Working on %q", pkgname)
+			defer logging.Group(pkgname)()
 
 			meta, err := apps.ReadMetaFile(path)
 			if err != nil {
-				log.Printf("Reading meta file %q: %s", path, err.Error())
+				slog.Warn("Reading meta file", logging.KeyFile, path, "err", err)
 				return nil
 			}
 
 			latestPackage, ok := fdroidIndex.FindLatestPackage(pkgname)
 			if !ok {
+				if prunedPackages[pkgname] {
+					// Every version was pruned
+					delete(meta, "CurrentVersion")
+					delete(meta, "CurrentVersionCode")
+					meta["Builds"] = make([]map[string]interface{}, 0)
+					g.writeMetaFile(path, meta)
+				}
 				return nil
 			}
 
-			log.Printf("The latest version is %q with versionCode %d", latestPackage.VersionName, latestPackage.VersionCode)
+			slog.Info("Latest version", "versionName", latestPackage.VersionName, "versionCode", latestPackage.VersionCode)
+
+			// The Builds follow the pruned index, also for the packages that weren't ingested this run
+			setBuilds(meta, latestPackage, fdroidIndex.Packages[pkgname])
 
 			apkInfo, ok := apkInfoMap[latestPackage.ApkName]
 			if !ok {
-				log.Printf("Cannot find apk info for %q", latestPackage.ApkName)
+				if prunedPackages[pkgname] {
+					g.writeMetaFile(path, meta)
+				} else {
+					slog.Warn("Cannot find apk info", "apk", latestPackage.ApkName)
+				}
 				return nil
 			}
 
+			var metadata apps.RepoMetadata
+			if g.plan.DryRun() {
+				g.plan.Record(plan.Action{Op: plan.OpClone, Path: apkInfo.GitURL, Detail: "store listing of " + latestPackage.PackageName})
+			} else {
+				slog.Info("Cloning git repo to search for metadata", "url", apkInfo.GitURL)
+
+				gitRepoPath, release, err := g.clones.Checkout(apkInfo.GitURL, apkInfo.Screenshots.CheckoutPaths())
+				if err != nil {
+					slog.Warn("Cloning git repo", "url", apkInfo.GitURL, "err", err)
+				} else {
+					defer release()
+					if metadata, err = apps.FindMetadata(gitRepoPath, apkInfo.Screenshots); err != nil {
+						slog.Warn("Finding metadata in git repo", "dir", gitRepoPath, "err", err)
+					}
+				}
+			}
+			// apps.yaml wins over the repository
+			apkInfo = apkInfo.WithRepoMetadata(metadata)
+
 			// Now update with some info
 
 			setNonEmpty(meta, "AuthorName", apkInfo.Author())
-			fn := apkInfo.FriendlyName
+			fn := apkInfo.FriendlyName.Get(apps.DefaultLocale)
 			if fn == "" {
 				fn = apkInfo.Name()
 			}
@@ -125,18 +214,8 @@ func (g *Globals) updateAndPull() error {
 			setNonEmpty(meta, "License", apkInfo.License)
 			setNonEmpty(meta, "WebSite", apkInfo.Website)
 			setNonEmpty(meta, "IssueTracker", apkInfo.IssueTracker)
-			setNonEmpty(meta, "Description", apkInfo.Description)
-
-			var summary = apkInfo.Summary
-			// See https://f-droid.org/en/docs/Build_Metadata_Reference/#Summary for max length
-			const maxSummaryLength = 80
-			if len(summary) > maxSummaryLength {
-				summary = summary[:maxSummaryLength-3] + "..."
-
-				log.Printf("Truncated summary to length of %d (max length)", len(summary))
-			}
-
-			setNonEmpty(meta, "Summary", summary)
+			setNonEmpty(meta, "Description", apkInfo.Description.Get(apps.DefaultLocale))
+			setNonEmpty(meta, "Summary", truncateSummary(apkInfo.Summary.Get(apps.DefaultLocale)))
 
 			if len(apkInfo.Categories) != 0 {
 				meta["Categories"] = apkInfo.Categories
@@ -146,107 +225,115 @@ func (g *Globals) updateAndPull() error {
 				meta["AntiFeatures"] = strings.Join(apkInfo.AntiFeatures, ",")
 			}
 
-			meta["CurrentVersion"] = latestPackage.VersionName
-			meta["CurrentVersionCode"] = latestPackage.VersionCode
-			builds := make([]map[string]interface{}, 0)
-			for _, p := range fdroidIndex.Packages[pkgname] {
-				build := make(map[string]interface{})
-				build["versionCode"] = p.VersionCode
-				build["versionName"] = p.VersionName
-				builds = append(builds, build)
+			if !g.writeMetaFile(path, meta) {
+				return nil
 			}
 
-			sortBuilds(builds)
-
-			meta["Builds"] = builds
-			log.Printf("Set current version info to versionName=%q, versionCode=%d", latestPackage.VersionName, latestPackage.VersionCode)
-
-			err = apps.WriteMetaFile(path, meta)
-			if err != nil {
-				log.Printf("Writing meta file %q: %s", path, err.Error())
+			pkgDir := filepath.Join(walkPath, latestPackage.PackageName)
+			if err = g.writeLocalized(pkgDir, apkInfo); err != nil {
+				slog.Error("Writing localized metadata", "dir", pkgDir, "err", err)
 				return nil
 			}
 
-			log.Printf("Updated metadata file %q", path)
-
+			changelog := fmt.Sprintf("%d.txt", latestPackage.VersionCode)
 			if apkInfo.ReleaseDescription != "" {
-				destFilePath := filepath.Join(walkPath, latestPackage.PackageName, "en-US", "changelogs", fmt.Sprintf("%d.txt", latestPackage.VersionCode))
-
-				err = os.MkdirAll(filepath.Dir(destFilePath), os.ModePerm)
-				if err != nil {
-					log.Printf("Creating directory for changelog file %q: %s", destFilePath, err.Error())
-					return nil
-				}
+				destFilePath := filepath.Join(pkgDir, apps.DefaultLocale, "changelogs", changelog)
 
-				err = os.WriteFile(destFilePath, []byte(apkInfo.ReleaseDescription), os.ModePerm)
+				err = g.plan.WriteFile(destFilePath, []byte(apkInfo.ReleaseDescription))
 				if err != nil {
-					log.Printf("Writing changelog file %q: %s", destFilePath, err.Error())
+					slog.Error("Writing changelog file", logging.KeyFile, destFilePath, "err", err)
 					return nil
 				}
 
-				log.Printf("Wrote release notes to %q", destFilePath)
-			}
-
-			log.Printf("Cloning git repository to search for screenshots")
-
-			gitRepoPath, err := git.CloneRepo(apkInfo.GitURL)
-			if err != nil {
-				log.Printf("Cloning git repo from %q: %s", apkInfo.GitURL, err.Error())
-				return nil
-			}
-			defer os.RemoveAll(gitRepoPath)
-
-			metadata, err := apps.FindMetadata(gitRepoPath)
-			if err != nil {
-				log.Printf("finding metadata in git repo %q: %s", gitRepoPath, err.Error())
-				return nil
-			}
-
-			metaIconPath := filepath.Join(gitRepoPath, "metadata", "en-US", "images", "icon.png")
-			iconPath := filepath.Join(walkPath, latestPackage.PackageName, "en-US", "icon.png")
-			err = file.Move(metaIconPath, iconPath)
-
-			if err != nil {
-				log.Printf("Copying icon file %q to %q: %s", metaIconPath, iconPath, err.Error())
+				slog.Info("Wrote release notes", logging.KeyFile, destFilePath)
 			}
 
-			log.Printf("Wrote icon to %s", iconPath)
-			toRemovePaths = append(toRemovePaths, iconPath)
-
-			log.Printf("Found %d screenshots", len(metadata.Screenshots))
-
-			screenshotsPath := filepath.Join(walkPath, latestPackage.PackageName, "en-US", "phoneScreenshots")
-
-			_ = os.RemoveAll(screenshotsPath)
-
-			var sccounter int = 1
-			for _, sc := range metadata.Screenshots {
-				var ext = filepath.Ext(sc)
-				if ext == "" {
-					log.Printf("Invalid: screenshot file extension is empty for %q", sc)
-					continue
+			for _, locale := range sortedKeys(metadata.Locales) {
+				m := metadata.Locales[locale]
+				localeDir := filepath.Join(pkgDir, locale)
+
+				// The release notes win over the upstream changelog
+				if src, ok := m.Changelog(latestPackage.VersionCode); ok && (locale != apps.DefaultLocale || apkInfo.ReleaseDescription == "") {
+					destFilePath := filepath.Join(localeDir, "changelogs", changelog)
+					// Copied as the clone is cached for the next runs
+					var data []byte
+					if data, err = os.ReadFile(src); err == nil {
+						err = g.plan.WriteFile(destFilePath, data)
+					}
+					if err != nil {
+						slog.Warn("Copying changelog file", logging.KeyFile, src, "to", destFilePath, "err", err)
+					} else {
+						slog.Info("Wrote changelog", "locale", locale, logging.KeyFile, destFilePath)
+					}
 				}
 
-				var newFilePath = filepath.Join(screenshotsPath, fmt.Sprintf("%d%s", sccounter, ext))
-
-				err = os.MkdirAll(filepath.Dir(newFilePath), os.ModePerm)
-				if err != nil {
-					log.Printf("Creating directory for screenshot file %q: %s", newFilePath, err.Error())
-					return nil
+				// The images are copied to the repo by fdroid, they are removed afterwards
+				for _, img := range []struct {
+					src, name string
+					normalise func(string) (images.Result, error)
+				}{{m.Icon, "icon", images.Icon}, {m.FeatureGraphic, "featureGraphic", images.FeatureGraphic}} {
+					if img.src == "" {
+						continue
+					}
+					r, ierr := g.publishImage(img.src, filepath.Join(localeDir, img.name), img.normalise)
+					if ierr != nil {
+						slog.Warn("Publishing image", logging.KeyFile, img.src, "err", ierr)
+						continue
+					}
+					toRemovePaths = append(toRemovePaths, filepath.Join(localeDir, img.name+r.Ext))
 				}
 
-				err = file.Move(sc, newFilePath)
-				if err != nil {
-					log.Printf("Moving screenshot file %q to %q: %s", sc, newFilePath, err.Error())
-					return nil
+				for _, kind := range apps.ScreenshotKinds {
+					screenshots, _, derr := apps.DedupeScreenshots(m.Screenshots[kind])
+					if derr != nil {
+						slog.Warn("Hashing screenshots", "locale", locale, "kind", kind, "err", derr)
+						continue
+					}
+					if len(screenshots) == 0 {
+						continue
+					}
+					slog.Info("Found screenshots", "locale", locale, "kind", kind, "count", len(screenshots))
+
+					screenshotsPath := filepath.Join(localeDir, kind)
+
+					_ = g.plan.RemoveAll(screenshotsPath)
+
+					var hashes []string
+					var sccounter int = 1
+					for _, sc := range screenshots {
+						r, ierr := g.publishImage(sc, filepath.Join(screenshotsPath, strconv.Itoa(sccounter)), func(path string) (images.Result, error) {
+							return images.Screenshot(path, apkInfo.Screenshots.MaxSize)
+						})
+						if ierr != nil {
+							slog.Warn("Publishing screenshot", logging.KeyFile, sc, "err", ierr)
+							continue
+						}
+						hashes = append(hashes, r.Hash())
+						sccounter++
+					}
+
+					// Unchanged screenshots stay as fdroid published them, the others replace them all
+					publishedPath := filepath.Join(g.RepoDir, latestPackage.PackageName, locale, kind)
+					if apps.SameScreenshots(publishedPath, hashes) {
+						slog.Info("Screenshots are unchanged", "locale", locale, "kind", kind)
+						_ = g.plan.RemoveAll(screenshotsPath)
+						continue
+					}
+					_ = g.plan.RemoveAll(publishedPath)
+
+					toRemovePaths = append(toRemovePaths, screenshotsPath)
+				}
+			}
+			if !g.plan.DryRun() && !metadata.HasIcon() {
+				// Without an icon in the git repo, the launcher icon of the APK is used
+				apkPath := filepath.Join(g.RepoDir, latestPackage.ApkName)
+				r, ierr := g.publishImage(apkPath, filepath.Join(pkgDir, apps.DefaultLocale, "icon"), launcherIcon)
+				if ierr != nil {
+					slog.Warn("No icon found in git repo or apk", "url", apkInfo.GitURL, "err", ierr)
+				} else {
+					toRemovePaths = append(toRemovePaths, filepath.Join(pkgDir, apps.DefaultLocale, "icon"+r.Ext))
 				}
-
-				log.Printf("Wrote screenshot to %s", newFilePath)
-
-				sccounter++
 			}
-
-			toRemovePaths = append(toRemovePaths, screenshotsPath)
 
 			return nil
 		}()
@@ -255,34 +342,62 @@ func (g *Globals) updateAndPull() error {
 		return err
 	}
 
-	if err := runFdroidUpdate(g.RepoDir); err != nil {
+	if err := g.runFdroidUpdate(); err != nil {
 		return err
 	}
 	for _, rmpath := range toRemovePaths {
-		err = os.RemoveAll(rmpath)
+		err = g.plan.RemoveAll(rmpath)
 		if err != nil {
-			log.Fatalf("removing path %q: %s\n", rmpath, err.Error())
+			return fmt.Errorf("removing path %q: %w", rmpath, err)
 		}
 	}
 
-	if err := apps.GenerateBadges(g.AppFile, g.RepoDir); err != nil {
+	if err := apps.GenerateBadges(g.plan, g.AppFile, g.RepoDir); err != nil {
 		return err
 	}
-	if err := md.RegenerateReadme(g.RepoDir); err != nil {
+	if err := md.RegenerateReadme(g.plan, g.RepoDir, g.ReadmeLocale); err != nil {
 		return err
 	}
 	return nil
 }
 
-func runFdroidUpdate(repoDir string) error {
+func (g *Globals) runFdroidUpdate() error {
 	cmd := exec.Command("fdroid", "update", "--pretty", "--create-metadata", "--delete-unknown", "--use-date-from-apk")
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
 	cmd.Stdin = os.Stdin
-	cmd.Dir = filepath.Dir(repoDir)
+	cmd.Dir = filepath.Dir(g.RepoDir)
 
-	log.Printf("Running %q in %s", cmd.String(), cmd.Dir)
-	return cmd.Run()
+	slog.Info("Running", "cmd", cmd.String(), "dir", cmd.Dir)
+	return g.plan.Run(cmd)
+}
+
+// setBuilds sets the current version and a Builds entry for each version of the package
+func setBuilds(meta map[string]interface{}, latest apps.PackageInfo, packages []apps.PackageInfo) {
+	meta["CurrentVersion"] = latest.VersionName
+	meta["CurrentVersionCode"] = latest.VersionCode
+	builds := make([]map[string]interface{}, 0)
+	for _, p := range packages {
+		build := make(map[string]interface{})
+		build["versionCode"] = p.VersionCode
+		build["versionName"] = p.VersionName
+		builds = append(builds, build)
+	}
+
+	sortBuilds(builds)
+
+	meta["Builds"] = builds
+	slog.Info("Set current version", "versionName", latest.VersionName, "versionCode", latest.VersionCode)
+}
+
+// writeMetaFile writes the metadata file, logging rather than returning the error so the other packages are updated
+func (g *Globals) writeMetaFile(path string, meta map[string]interface{}) bool {
+	if err := apps.WriteMetaFile(g.plan, path, meta); err != nil {
+		slog.Error("Writing meta file", logging.KeyFile, path, "err", err)
+		return false
+	}
+	slog.Info("Updated metadata file", logging.KeyFile, path)
+	return true
 }
 
 func sortBuilds(builds []map[string]interface{}) {
@@ -291,11 +406,85 @@ func sortBuilds(builds []map[string]interface{}) {
 	})
 }
 
+// localizedFiles are the per-locale text files F-Droid reads next to the metadata file
+var localizedFiles = []struct {
+	name string
+	text func(app *apps.AppInfo, locale string) string
+}{
+	{"name.txt", func(app *apps.AppInfo, locale string) string { return app.FriendlyName[locale] }},
+	{"summary.txt", func(app *apps.AppInfo, locale string) string { return truncateSummary(app.Summary[locale]) }},
+	{"description.txt", func(app *apps.AppInfo, locale string) string { return app.Description[locale] }},
+}
+
+// writeLocalized writes the name, summary and description of the locales other than the default one,
+// which lives in the metadata file
+func (g *Globals) writeLocalized(pkgDir string, app *apps.AppInfo) error {
+	for _, locale := range app.Locales() {
+		if locale == apps.DefaultLocale {
+			continue
+		}
+		for _, f := range localizedFiles {
+			text := f.text(app, locale)
+			if text == "" {
+				continue
+			}
+			if err := g.plan.WriteFile(filepath.Join(pkgDir, locale, f.name), []byte(text)); err != nil {
+				return err
+			}
+		}
+		slog.Info("Wrote metadata", "locale", locale)
+	}
+	return nil
+}
+
+// publishImage normalises src and writes it to dst with the extension of its format
+func (g *Globals) publishImage(src, dst string, normalise func(string) (images.Result, error)) (r images.Result, err error) {
+	if r, err = normalise(src); err != nil {
+		return
+	}
+	for _, v := range r.Violations {
+		slog.Warn(v, logging.KeyFile, src)
+	}
+	if err = g.plan.WriteFile(dst+r.Ext, r.Data); err != nil {
+		return
+	}
+	slog.Info("Wrote image", logging.KeyFile, dst+r.Ext, "width", r.Width, "height", r.Height)
+	return
+}
+
+// launcherIcon normalises the launcher icon of the APK at path
+func launcherIcon(path string) (r images.Result, err error) {
+	img, err := apk.ReadIcon(path)
+	if err != nil {
+		return
+	}
+	return images.IconImage(img)
+}
+
+// truncateSummary cuts summary to the length F-Droid accepts
+func truncateSummary(summary string) string {
+	if len(summary) <= apps.MaxSummaryLength {
+		return summary
+	}
+	summary = summary[:apps.MaxSummaryLength-3] + "..."
+	slog.Info("Truncated summary to the maximum length", "length", len(summary))
+	return summary
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
 func setNonEmpty(m map[string]interface{}, key string, value string) {
 	if value != "" || m[key] == "Unknown" {
 		m[key] = value
 
-		log.Printf("Set %s to %q", key, value)
+		slog.Info("Set metadata", "key", key, "value", value)
 	}
 }
 func Run() {
@@ -308,5 +497,15 @@ func Run() {
 			Compact: true,
 		}))
 	err := ctx.Run(&cli.Globals)
-	ctx.FatalIfErrorf(err)
+	if err == nil && cli.DryRun {
+		if cli.PlanFormat == "json" {
+			err = cli.plan.WriteJSON(os.Stdout)
+		} else {
+			err = cli.plan.WriteText(os.Stdout)
+		}
+	}
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"metascoop/logging"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+type StatusCmd struct {
+	JSON    bool `help:"Print the status as JSON" default:"false"`
+	Offline bool `help:"Don't look up the upstream releases" default:"false"`
+}
+
+func (c *StatusCmd) Run(g *Globals) error {
+	status, err := g.loader.Status(g.RepoDir, c.Offline)
+	if err != nil {
+		return err
+	}
+
+	if c.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "APP\tREPO\tMETADATA\tUPSTREAM\tPRS\tBADGE\tISSUES")
+	for _, s := range status.Apps {
+		var current, prs []string
+		for _, p := range s.Packages {
+			if p.CurrentVersion != "" && !slices.Contains(current, p.CurrentVersion) {
+				current = append(current, p.CurrentVersion)
+			}
+		}
+		metadata := strings.Join(current, ", ")
+		if len(current) > 2 {
+			metadata = fmt.Sprintf("%d packages", len(s.Packages))
+		}
+		for _, n := range s.PRs {
+			prs = append(prs, fmt.Sprintf("#%d", n))
+		}
+		repo := ""
+		if latest := s.Latest(); latest != "" {
+			repo = fmt.Sprintf("%s (%d)", latest, len(s.Versions))
+		}
+		fmt.Fprintln(w, strings.Join([]string{
+			s.App,
+			dash(repo),
+			dash(metadata),
+			dash(s.Upstream),
+			dash(strings.Join(prs, ",")),
+			dash(s.Badge),
+			dash(strings.Join(s.Issues, "; ")),
+		}, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(status.Orphans) != 0 {
+		slog.Warn("Packages without an app", logging.KeyFile, g.AppFile, "packages", strings.Join(status.Orphans, ", "))
+	}
+	return nil
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
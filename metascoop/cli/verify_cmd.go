@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"metascoop/apps"
+	"metascoop/logging"
+)
+
+type VerifyCmd struct{}
+
+func (c *VerifyCmd) Run(g *Globals) error {
+	problems, err := apps.Verify(g.RepoDir)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		slog.Error(p.Message, logging.KeyFile, p.Path)
+	}
+	if len(problems) != 0 {
+		return fmt.Errorf("found %d problems in %q", len(problems), g.RepoDir)
+	}
+	slog.Info("Repo is consistent with its index", "dir", g.RepoDir)
+	return nil
+}
@@ -0,0 +1,151 @@
+package forge
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CacheHeader is set on responses served from the cache after a 304
+const CacheHeader = "X-Metascoop-Cache"
+
+// maxCachedBody keeps release assets and other large downloads out of the cache
+const maxCachedBody = 8 << 20
+
+// CacheTransport stores JSON API responses on disk with their ETag and Last-Modified
+// and revalidates them with conditional requests
+type CacheTransport struct {
+	Dir  string
+	Base http.RoundTripper
+}
+
+type cacheEntry struct {
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+func NewCacheTransport(dir string, base http.RoundTripper) *CacheTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &CacheTransport{Dir: dir, Base: base}
+}
+
+func (t *CacheTransport) key(req *http.Request) string {
+	h := sha256.New()
+	h.Write([]byte(req.URL.String()))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Header.Get("Accept")))
+	h.Write([]byte{0})
+	// Different credentials may see different content
+	h.Write([]byte(req.Header.Get("Authorization") + req.Header.Get("PRIVATE-TOKEN")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (t *CacheTransport) load(key string) (entry *cacheEntry) {
+	b, err := os.ReadFile(filepath.Join(t.Dir, key+".json"))
+	if err != nil {
+		return nil
+	}
+	if err = json.Unmarshal(b, &entry); err != nil {
+		return nil
+	}
+	return
+}
+
+func (t *CacheTransport) store(key string, entry *cacheEntry) (err error) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(t.Dir, os.ModePerm); err != nil {
+		return
+	}
+	path := filepath.Join(t.Dir, key+".json")
+	tmp, err := os.CreateTemp(t.Dir, key+"-*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (t *CacheTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.Base.RoundTrip(req)
+	}
+
+	key := t.key(req)
+	entry := t.load(key)
+	if entry != nil {
+		req = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lm := entry.Header.Get("Last-Modified"); lm != "" {
+			req.Header.Set("If-Modified-Since", lm)
+		}
+	}
+
+	resp, err = t.Base.RoundTrip(req)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		_ = resp.Body.Close()
+		header := entry.Header.Clone()
+		// Fresh rate limit and validator headers win over the stored ones
+		for k, v := range resp.Header {
+			header[k] = v
+		}
+		header.Set(CacheHeader, "revalidated")
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+			ContentLength: int64(len(entry.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	if resp.StatusCode != http.StatusOK ||
+		(resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "") ||
+		!strings.Contains(resp.Header.Get("Content-Type"), "json") ||
+		resp.ContentLength > maxCachedBody {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedBody+1))
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) > maxCachedBody {
+		return
+	}
+	if serr := t.store(key, &cacheEntry{URL: req.URL.Redacted(), Header: resp.Header, Body: body}); serr != nil {
+		slog.WarnContext(req.Context(), "Caching response", "url", req.URL.Redacted(), "err", serr)
+	}
+	return
+}
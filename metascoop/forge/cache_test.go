@@ -0,0 +1,63 @@
+package forge
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestCacheTransportSeparatesCredentials(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		etag := `"` + r.Header.Get("Authorization") + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"auth":"`+r.Header.Get("Authorization")+`"}`)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	client := func(token string) *http.Client {
+		base := http.RoundTripper(NewCacheTransport(dir, http.DefaultTransport))
+		if token != "" {
+			base = &oauth2.Transport{Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}), Base: base}
+		}
+		return &http.Client{Transport: base}
+	}
+	get := func(c *http.Client) (body, cache string) {
+		t.Helper()
+		resp, err := c.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b), resp.Header.Get(CacheHeader)
+	}
+
+	for _, tc := range []struct {
+		token, body, cache string
+	}{
+		{"", `{"auth":""}`, ""},
+		{"a", `{"auth":"Bearer a"}`, ""},
+		{"b", `{"auth":"Bearer b"}`, ""},
+		{"a", `{"auth":"Bearer a"}`, "revalidated"},
+		{"", `{"auth":""}`, "revalidated"},
+	} {
+		body, cache := get(client(tc.token))
+		if body != tc.body || cache != tc.cache {
+			t.Errorf("token %q: got %s (cache %q), want %s (cache %q)", tc.token, body, cache, tc.body, tc.cache)
+		}
+	}
+	if requests != 5 {
+		t.Errorf("got %d requests, want 5", requests)
+	}
+}
@@ -0,0 +1,174 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+// Kinds of supported forges
+const (
+	KindGitHub = "github"
+	KindGitLab = "gitlab"
+	KindGitea  = "gitea"
+)
+
+type Repository struct {
+	Description string
+	// License is the SPDX identifier when the forge knows it
+	License string
+}
+
+type Asset struct {
+	ID   int64
+	Name string
+	Size int64
+	// URL is the direct download URL, when the forge has one
+	URL string
+}
+
+type Release struct {
+	TagName     string
+	Name        string
+	Body        string
+	Draft       bool
+	Prerelease  bool
+	PublishedAt time.Time
+	Assets      []Asset
+	// URL is the web page of the release
+	URL string
+}
+
+type PullRequest struct {
+	Number    int
+	Title     string
+	Body      string
+	State     string
+	Merged    bool
+	Labels    []string
+	HeadSHA   string
+	BaseSHA   string
+	CreatedAt time.Time
+	ClosedAt  time.Time
+	// URL is the web page of the pull request
+	URL string
+}
+
+// Open reports whether the pull request is still open
+func (p *PullRequest) Open() bool {
+	return p.State == "open" || p.State == "opened"
+}
+
+type Commit struct {
+	SHA     string
+	Message string
+	Author  string
+	Date    time.Time
+}
+
+// Subject returns the first line of the commit message
+func (c *Commit) Subject() string {
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return strings.TrimSpace(subject)
+}
+
+// Forge is what the loader needs from a code hosting service
+type Forge interface {
+	Repository(ctx context.Context, owner, name string) (*Repository, error)
+	ListReleases(ctx context.Context, owner, name string) ([]*Release, error)
+	ReleaseByTag(ctx context.Context, owner, name, tag string) (*Release, error)
+	DownloadAsset(ctx context.Context, owner, name string, asset Asset) (io.ReadCloser, error)
+	PullRequest(ctx context.Context, owner, name string, number int) (*PullRequest, error)
+	// PullRequestCommits returns the commits of a pull request since its base, oldest first
+	PullRequestCommits(ctx context.Context, owner, name string, number int) ([]*Commit, error)
+	Commit(ctx context.Context, owner, name, sha string) (*Commit, error)
+	// DownloadArtifact returns the zip archive of a CI artifact
+	DownloadArtifact(ctx context.Context, owner, name string, id int64) (io.ReadCloser, error)
+}
+
+type Options struct {
+	GitHubClient *github.Client
+	HTTPClient   *http.Client
+	// Tokens maps a host to its access token
+	Tokens map[string]string
+}
+
+// Registry creates and caches a Forge per host
+type Registry struct {
+	opts   Options
+	mu     sync.Mutex
+	forges map[string]Forge
+}
+
+func NewRegistry(opts Options) *Registry {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.GitHubClient == nil {
+		opts.GitHubClient = github.NewClient(opts.HTTPClient)
+	}
+	return &Registry{opts: opts, forges: make(map[string]Forge)}
+}
+
+// DetectKind guesses the forge kind from well-known hosts and host names
+func DetectKind(host string) (kind string, err error) {
+	switch {
+	case host == "github.com":
+		kind = KindGitHub
+	case host == "gitlab.com" || strings.Contains(host, "gitlab"):
+		kind = KindGitLab
+	case host == "codeberg.org" || strings.Contains(host, "gitea") || strings.Contains(host, "forgejo"):
+		kind = KindGitea
+	default:
+		err = fmt.Errorf("cannot detect the forge of %q, set it explicitly", host)
+	}
+	return
+}
+
+// For returns the forge of kind for host, kind being detected from the host when empty
+func (r *Registry) For(kind, host string) (f Forge, err error) {
+	if kind == "" {
+		if kind, err = DetectKind(host); err != nil {
+			return
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := kind + "/" + host
+	if f, ok := r.forges[key]; ok {
+		return f, nil
+	}
+	token := r.opts.Tokens[host]
+	switch kind {
+	case KindGitHub:
+		if host != "github.com" {
+			f, err = newGitHubEnterprise(r.opts.HTTPClient, host, token)
+		} else {
+			f = &GitHub{client: r.opts.GitHubClient, http: r.opts.HTTPClient}
+		}
+	case KindGitLab:
+		f = NewGitLab(r.opts.HTTPClient, "https://"+host, token)
+	case KindGitea:
+		f = NewGitea(r.opts.HTTPClient, "https://"+host, token)
+	default:
+		err = fmt.Errorf("unknown forge %q for %q", kind, host)
+	}
+	if err == nil {
+		r.forges[key] = f
+	}
+	return
+}
+
+// Register sets the forge used for host, replacing any other
+func (r *Registry) Register(kind, host string, f Forge) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.forges[kind+"/"+host] = f
+}
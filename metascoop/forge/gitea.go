@@ -0,0 +1,205 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Gitea talks to the API v1 of Gitea and Forgejo instances, like Codeberg
+type Gitea struct {
+	api *apiClient
+}
+
+// NewGitea returns a Gitea backend for the instance at baseURL, e.g. https://codeberg.org
+func NewGitea(client *http.Client, baseURL, token string) *Gitea {
+	api := &apiClient{client: client, baseURL: baseURL}
+	if token != "" {
+		api.auth = func(req *http.Request) { req.Header.Set("Authorization", "token "+token) }
+	}
+	return &Gitea{api: api}
+}
+
+func giteaRepo(owner, name string) string {
+	return "/api/v1/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(name)
+}
+
+type giteaRelease struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Body        string    `json:"body"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+	HTMLURL     string    `json:"html_url"`
+	Assets      []struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+		Size int64  `json:"size"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func (r *giteaRelease) convert() *Release {
+	rel := &Release{
+		TagName:     r.TagName,
+		Name:        r.Name,
+		Body:        r.Body,
+		Draft:       r.Draft,
+		Prerelease:  r.Prerelease,
+		PublishedAt: r.PublishedAt,
+		URL:         r.HTMLURL,
+	}
+	for _, a := range r.Assets {
+		rel.Assets = append(rel.Assets, Asset{ID: a.ID, Name: a.Name, Size: a.Size, URL: a.URL})
+	}
+	return rel
+}
+
+func (g *Gitea) Repository(ctx context.Context, owner, name string) (r *Repository, err error) {
+	var repo struct {
+		Description string   `json:"description"`
+		Licenses    []string `json:"licenses"`
+	}
+	_, err = g.api.getJSON(ctx, giteaRepo(owner, name), &repo)
+	if err != nil {
+		return
+	}
+	r = &Repository{Description: repo.Description}
+	if len(repo.Licenses) != 0 {
+		r.License = repo.Licenses[0]
+	}
+	return
+}
+
+func (g *Gitea) ListReleases(ctx context.Context, owner, name string) (releases []*Release, err error) {
+	const limit = 50
+	for page := 1; ; page++ {
+		var rels []giteaRelease
+		_, err = g.api.getJSON(ctx, fmt.Sprintf("%s/releases?limit=%d&page=%d", giteaRepo(owner, name), limit, page), &rels)
+		if err != nil {
+			return
+		}
+		for i := range rels {
+			releases = append(releases, rels[i].convert())
+		}
+		// Instances cap the limit to their own maximum, only an empty page is the last
+		if len(rels) == 0 {
+			return
+		}
+	}
+}
+
+func (g *Gitea) ReleaseByTag(ctx context.Context, owner, name, tag string) (r *Release, err error) {
+	var rel giteaRelease
+	_, err = g.api.getJSON(ctx, giteaRepo(owner, name)+"/releases/tags/"+url.PathEscape(tag), &rel)
+	if err != nil {
+		return
+	}
+	return rel.convert(), nil
+}
+
+func (g *Gitea) DownloadAsset(ctx context.Context, owner, name string, asset Asset) (io.ReadCloser, error) {
+	return g.api.download(ctx, asset.URL)
+}
+
+func (g *Gitea) PullRequest(ctx context.Context, owner, name string, number int) (p *PullRequest, err error) {
+	var pr struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		Body   string `json:"body"`
+		State  string `json:"state"`
+		Merged bool   `json:"merged"`
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+		MergeBase string     `json:"merge_base"`
+		CreatedAt time.Time  `json:"created_at"`
+		ClosedAt  *time.Time `json:"closed_at"`
+		HTMLURL   string     `json:"html_url"`
+	}
+	_, err = g.api.getJSON(ctx, fmt.Sprintf("%s/pulls/%d", giteaRepo(owner, name), number), &pr)
+	if err != nil {
+		return
+	}
+	p = &PullRequest{
+		Number:    pr.Number,
+		Title:     pr.Title,
+		Body:      pr.Body,
+		State:     pr.State,
+		Merged:    pr.Merged,
+		HeadSHA:   pr.Head.SHA,
+		BaseSHA:   pr.MergeBase,
+		CreatedAt: pr.CreatedAt,
+		URL:       pr.HTMLURL,
+	}
+	for _, l := range pr.Labels {
+		p.Labels = append(p.Labels, l.Name)
+	}
+	if pr.ClosedAt != nil {
+		p.ClosedAt = *pr.ClosedAt
+	}
+	return
+}
+
+type giteaCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name string    `json:"name"`
+			Date time.Time `json:"date"`
+		} `json:"author"`
+	} `json:"commit"`
+	Author *struct {
+		Login string `json:"login"`
+	} `json:"author"`
+}
+
+func (c *giteaCommit) convert() *Commit {
+	commit := &Commit{SHA: c.SHA, Message: c.Commit.Message, Author: c.Commit.Author.Name, Date: c.Commit.Author.Date}
+	if c.Author != nil && c.Author.Login != "" {
+		commit.Author = c.Author.Login
+	}
+	return commit
+}
+
+func (g *Gitea) PullRequestCommits(ctx context.Context, owner, name string, number int) (commits []*Commit, err error) {
+	const limit = 50
+	for page := 1; ; page++ {
+		var cs []giteaCommit
+		_, err = g.api.getJSON(ctx, fmt.Sprintf("%s/pulls/%d/commits?limit=%d&page=%d", giteaRepo(owner, name), number, limit, page), &cs)
+		if err != nil {
+			return
+		}
+		for i := range cs {
+			commits = append(commits, cs[i].convert())
+		}
+		if len(cs) == 0 {
+			break
+		}
+	}
+	// Gitea lists the newest commits first
+	slices.Reverse(commits)
+	return
+}
+
+func (g *Gitea) Commit(ctx context.Context, owner, name, sha string) (c *Commit, err error) {
+	var commit giteaCommit
+	_, err = g.api.getJSON(ctx, giteaRepo(owner, name)+"/git/commits/"+url.PathEscape(sha), &commit)
+	if err != nil {
+		return
+	}
+	return commit.convert(), nil
+}
+
+func (g *Gitea) DownloadArtifact(ctx context.Context, owner, name string, id int64) (io.ReadCloser, error) {
+	return g.api.download(ctx, fmt.Sprintf("%s/actions/artifacts/%d/zip", giteaRepo(owner, name), id))
+}
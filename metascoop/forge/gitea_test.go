@@ -0,0 +1,100 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// giteaServer serves pages of at most three items, less than the limit asked for,
+// the way instances capping MAX_RESPONSE_ITEMS do
+func giteaServer(t *testing.T, items map[string][]map[string]interface{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "token secret" {
+			t.Errorf("%s: Authorization %q", r.URL.Path, got)
+		}
+		all, ok := items[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		start, end := min((page-1)*3, len(all)), min(page*3, len(all))
+		_ = json.NewEncoder(w).Encode(all[start:end])
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGiteaListReleases(t *testing.T) {
+	var rels []map[string]interface{}
+	for i := 1; i <= 7; i++ {
+		rels = append(rels, map[string]interface{}{
+			"tag_name":   fmt.Sprintf("v1.%d.0", i),
+			"prerelease": i == 7,
+			"html_url":   fmt.Sprintf("https://codeberg.org/o/r/releases/tag/v1.%d.0", i),
+			"assets": []map[string]interface{}{
+				{"id": i, "name": "app.apk", "size": 100, "browser_download_url": "https://codeberg.org/o/r/app.apk"},
+			},
+		})
+	}
+	srv := giteaServer(t, map[string][]map[string]interface{}{"/api/v1/repos/o/r/releases": rels})
+
+	releases, err := NewGitea(srv.Client(), srv.URL, "secret").ListReleases(context.Background(), "o", "r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 7 {
+		t.Fatalf("got %d releases, want 7", len(releases))
+	}
+	last := releases[6]
+	if last.TagName != "v1.7.0" || !last.Prerelease || len(last.Assets) != 1 || last.Assets[0].ID != 7 {
+		t.Errorf("got %+v", last)
+	}
+}
+
+func TestGiteaPullRequestCommits(t *testing.T) {
+	var commits []map[string]interface{}
+	// Newest first
+	for i := 5; i >= 1; i-- {
+		c := map[string]interface{}{"sha": fmt.Sprint(i)}
+		c["commit"] = map[string]interface{}{
+			"message": fmt.Sprintf("Commit %d\n\nBody", i),
+			"author":  map[string]interface{}{"name": "Git Name", "date": "2024-01-02T03:04:05Z"},
+		}
+		if i != 1 {
+			c["author"] = map[string]interface{}{"login": "login"}
+		}
+		commits = append(commits, c)
+	}
+	srv := giteaServer(t, map[string][]map[string]interface{}{"/api/v1/repos/o/r/pulls/3/commits": commits})
+
+	got, err := NewGitea(srv.Client(), srv.URL, "secret").PullRequestCommits(context.Background(), "o", "r", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 5 {
+		t.Fatalf("got %d commits, want 5", len(got))
+	}
+	for i, c := range got {
+		if c.SHA != fmt.Sprint(i+1) {
+			t.Errorf("commit %d: got %s", i, c.SHA)
+		}
+	}
+	if got[0].Author != "Git Name" || got[1].Author != "login" || got[1].Subject() != "Commit 2" {
+		t.Errorf("got %+v, %+v", got[0], got[1])
+	}
+}
+
+func TestGiteaError(t *testing.T) {
+	srv := giteaServer(t, nil)
+	_, err := NewGitea(srv.Client(), srv.URL, "secret").ReleaseByTag(context.Background(), "o", "r", "v1")
+	if err == nil {
+		t.Fatal("got no error for a missing release")
+	}
+}
@@ -0,0 +1,210 @@
+package forge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/go-github/v61/github"
+)
+
+type GitHub struct {
+	client *github.Client
+	http   *http.Client
+}
+
+func NewGitHub(client *github.Client, httpClient *http.Client) *GitHub {
+	return &GitHub{client: client, http: httpClient}
+}
+
+func newGitHubEnterprise(httpClient *http.Client, host, token string) (f *GitHub, err error) {
+	client := github.NewClient(httpClient)
+	if token != "" {
+		client = client.WithAuthToken(token)
+	}
+	client, err = client.WithEnterpriseURLs("https://"+host+"/api/v3/", "https://"+host+"/api/uploads/")
+	if err != nil {
+		return
+	}
+	return NewGitHub(client, httpClient), nil
+}
+
+// call runs fn, waiting once for the rate limit reset when go-github refused to send the
+// request because it already knows the quota is exhausted
+func call[T any](ctx context.Context, fn func() (T, *github.Response, error)) (v T, resp *github.Response, err error) {
+	v, resp, err = fn()
+	var rle *github.RateLimitError
+	if !errors.As(err, &rle) {
+		return
+	}
+	wait := time.Until(rle.Rate.Reset.Time) + time.Second
+	if wait > maxRateLimitWait {
+		return
+	}
+	slog.WarnContext(ctx, "GitHub rate limit exhausted, waiting for the reset", "wait", wait.Round(time.Second))
+	if err = sleep(ctx, wait); err != nil {
+		return
+	}
+	return fn()
+}
+
+func (g *GitHub) Repository(ctx context.Context, owner, name string) (r *Repository, err error) {
+	repo, _, err := call(ctx, func() (*github.Repository, *github.Response, error) {
+		return g.client.Repositories.Get(ctx, owner, name)
+	})
+	if err != nil {
+		return
+	}
+	r = &Repository{Description: repo.GetDescription()}
+	if repo.License != nil {
+		r.License = repo.License.GetSPDXID()
+	}
+	return
+}
+
+func convertGitHubRelease(rel *github.RepositoryRelease) *Release {
+	r := &Release{
+		TagName:     rel.GetTagName(),
+		Name:        rel.GetName(),
+		Body:        rel.GetBody(),
+		Draft:       rel.GetDraft(),
+		Prerelease:  rel.GetPrerelease(),
+		PublishedAt: rel.GetPublishedAt().Time,
+		URL:         rel.GetHTMLURL(),
+	}
+	for _, asset := range rel.Assets {
+		if asset.GetState() != "uploaded" {
+			continue
+		}
+		r.Assets = append(r.Assets, Asset{
+			ID:   asset.GetID(),
+			Name: asset.GetName(),
+			Size: int64(asset.GetSize()),
+			URL:  asset.GetBrowserDownloadURL(),
+		})
+	}
+	return r
+}
+
+func (g *GitHub) ListReleases(ctx context.Context, owner, name string) (releases []*Release, err error) {
+	opts := &github.ListOptions{Page: 1, PerPage: 100}
+	for {
+		rels, resp, ierr := call(ctx, func() ([]*github.RepositoryRelease, *github.Response, error) {
+			return g.client.Repositories.ListReleases(ctx, owner, name, opts)
+		})
+		if ierr != nil {
+			err = ierr
+			return
+		}
+		for _, rel := range rels {
+			releases = append(releases, convertGitHubRelease(rel))
+		}
+		if resp.NextPage == 0 {
+			return
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *GitHub) ReleaseByTag(ctx context.Context, owner, name, tag string) (r *Release, err error) {
+	rel, _, err := call(ctx, func() (*github.RepositoryRelease, *github.Response, error) {
+		return g.client.Repositories.GetReleaseByTag(ctx, owner, name, tag)
+	})
+	if err != nil {
+		return
+	}
+	return convertGitHubRelease(rel), nil
+}
+
+func (g *GitHub) DownloadAsset(ctx context.Context, owner, name string, asset Asset) (rc io.ReadCloser, err error) {
+	rc, _, err = call(ctx, func() (io.ReadCloser, *github.Response, error) {
+		rc, _, err := g.client.Repositories.DownloadReleaseAsset(ctx, owner, name, asset.ID, g.http)
+		return rc, nil, err
+	})
+	return
+}
+
+func (g *GitHub) PullRequest(ctx context.Context, owner, name string, number int) (p *PullRequest, err error) {
+	pr, _, err := call(ctx, func() (*github.PullRequest, *github.Response, error) {
+		return g.client.PullRequests.Get(ctx, owner, name, number)
+	})
+	if err != nil {
+		return
+	}
+	p = &PullRequest{
+		Number:    pr.GetNumber(),
+		Title:     pr.GetTitle(),
+		Body:      pr.GetBody(),
+		State:     pr.GetState(),
+		Merged:    pr.GetMerged(),
+		HeadSHA:   pr.GetHead().GetSHA(),
+		BaseSHA:   pr.GetBase().GetSHA(),
+		CreatedAt: pr.GetCreatedAt().Time,
+		ClosedAt:  pr.GetClosedAt().Time,
+		URL:       pr.GetHTMLURL(),
+	}
+	for _, l := range pr.Labels {
+		p.Labels = append(p.Labels, l.GetName())
+	}
+	return
+}
+
+func convertGitHubCommit(sha string, c *github.Commit, author *github.User) *Commit {
+	commit := &Commit{
+		SHA:     sha,
+		Message: c.GetMessage(),
+		Author:  c.GetAuthor().GetName(),
+		Date:    c.GetAuthor().GetDate().Time,
+	}
+	// The login links to the account, the name is whatever git was configured with
+	if author.GetLogin() != "" {
+		commit.Author = author.GetLogin()
+	}
+	return commit
+}
+
+func (g *GitHub) PullRequestCommits(ctx context.Context, owner, name string, number int) (commits []*Commit, err error) {
+	opts := &github.ListOptions{Page: 1, PerPage: 100}
+	for {
+		cs, resp, ierr := call(ctx, func() ([]*github.RepositoryCommit, *github.Response, error) {
+			return g.client.PullRequests.ListCommits(ctx, owner, name, number, opts)
+		})
+		if ierr != nil {
+			err = ierr
+			return
+		}
+		for _, c := range cs {
+			commits = append(commits, convertGitHubCommit(c.GetSHA(), c.GetCommit(), c.GetAuthor()))
+		}
+		if resp.NextPage == 0 {
+			return
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (g *GitHub) Commit(ctx context.Context, owner, name, sha string) (c *Commit, err error) {
+	rc, _, err := call(ctx, func() (*github.RepositoryCommit, *github.Response, error) {
+		return g.client.Repositories.GetCommit(ctx, owner, name, sha, nil)
+	})
+	if err != nil {
+		return
+	}
+	return convertGitHubCommit(rc.GetSHA(), rc.GetCommit(), rc.GetAuthor()), nil
+}
+
+func (g *GitHub) DownloadArtifact(ctx context.Context, owner, name string, id int64) (rc io.ReadCloser, err error) {
+	u, _, err := call(ctx, func() (*url.URL, *github.Response, error) {
+		return g.client.Actions.DownloadArtifact(ctx, owner, name, id, 1)
+	})
+	if err != nil {
+		return
+	}
+	// The URL is pre-signed, it mustn't carry the GitHub credentials
+	c := &apiClient{client: g.http}
+	return c.download(ctx, u.String())
+}
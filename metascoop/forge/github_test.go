@@ -0,0 +1,87 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v61/github"
+)
+
+func newTestGitHub(t *testing.T, handler http.Handler) *GitHub {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	client, err := github.NewClient(srv.Client()).WithEnterpriseURLs(srv.URL+"/", srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewGitHub(client, srv.Client())
+}
+
+func TestGitHubListReleases(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/o/r/releases", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/api/v3/repos/o/r/releases?page=2>; rel="next"`, r.Host))
+			_, _ = w.Write([]byte(`[{"tag_name":"v2.0.0","prerelease":true,"html_url":"https://github.com/o/r/releases/tag/v2.0.0",
+				"assets":[{"id":1,"name":"app.apk","size":10,"state":"uploaded","browser_download_url":"https://github.com/o/r/app.apk"},
+				{"id":2,"name":"partial.apk","state":"open"}]}]`))
+		case "2":
+			_, _ = w.Write([]byte(`[{"tag_name":"v1.0.0","draft":true}]`))
+		default:
+			t.Errorf("unexpected page %q", r.URL.Query().Get("page"))
+		}
+	})
+	g := newTestGitHub(t, mux)
+
+	releases, err := g.ListReleases(context.Background(), "o", "r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 2 {
+		t.Fatalf("got %d releases, want 2", len(releases))
+	}
+	r := releases[0]
+	if r.TagName != "v2.0.0" || !r.Prerelease || r.URL != "https://github.com/o/r/releases/tag/v2.0.0" {
+		t.Errorf("got %+v", r)
+	}
+	// Assets still being uploaded are skipped
+	if len(r.Assets) != 1 || r.Assets[0].ID != 1 || r.Assets[0].Size != 10 {
+		t.Errorf("got assets %+v", r.Assets)
+	}
+	if !releases[1].Draft {
+		t.Errorf("got %+v", releases[1])
+	}
+}
+
+func TestGitHubPullRequestCommits(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/o/r/pulls/5", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"number":5,"state":"open","labels":[{"name":"fdroid"}],"head":{"sha":"head"},"base":{"sha":"base"}}`))
+	})
+	mux.HandleFunc("/api/v3/repos/o/r/pulls/5/commits", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"sha":"1","commit":{"message":"First\n\nBody","author":{"name":"Git Name","date":"2024-01-02T03:04:05Z"}}},
+			{"sha":"2","commit":{"message":"Second"},"author":{"login":"login"}}]`))
+	})
+	g := newTestGitHub(t, mux)
+
+	pr, err := g.PullRequest(context.Background(), "o", "r", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pr.Open() || pr.HeadSHA != "head" || pr.BaseSHA != "base" || len(pr.Labels) != 1 || pr.Labels[0] != "fdroid" {
+		t.Errorf("got %+v", pr)
+	}
+
+	commits, err := g.PullRequestCommits(context.Background(), "o", "r", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || commits[0].Subject() != "First" || commits[0].Author != "Git Name" || commits[1].Author != "login" {
+		t.Errorf("got %+v", commits)
+	}
+}
@@ -0,0 +1,211 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+// GitLab talks to the GitLab REST API v4
+type GitLab struct {
+	api *apiClient
+}
+
+// NewGitLab returns a GitLab backend for the instance at baseURL, e.g. https://gitlab.com
+func NewGitLab(client *http.Client, baseURL, token string) *GitLab {
+	api := &apiClient{client: client, baseURL: baseURL}
+	if token != "" {
+		api.auth = func(req *http.Request) { req.Header.Set("PRIVATE-TOKEN", token) }
+	}
+	return &GitLab{api: api}
+}
+
+func gitlabProject(owner, name string) string {
+	return "/api/v4/projects/" + url.PathEscape(owner+"/"+name)
+}
+
+type gitlabRelease struct {
+	TagName    string    `json:"tag_name"`
+	Name       string    `json:"name"`
+	Desc       string    `json:"description"`
+	Upcoming   bool      `json:"upcoming_release"`
+	ReleasedAt time.Time `json:"released_at"`
+	Links      struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+	Assets struct {
+		Links []struct {
+			ID             int64  `json:"id"`
+			Name           string `json:"name"`
+			URL            string `json:"url"`
+			DirectAssetURL string `json:"direct_asset_url"`
+		} `json:"links"`
+	} `json:"assets"`
+}
+
+// prereleaseTag reports whether tag, with or without its v prefix, is a prerelease version
+func prereleaseTag(tag string) bool {
+	v, err := version.NewVersion(tag)
+	return err == nil && v.Prerelease() != ""
+}
+
+func (r *gitlabRelease) convert() *Release {
+	rel := &Release{
+		TagName: r.TagName,
+		Name:    r.Name,
+		Body:    r.Desc,
+		// GitLab has no prerelease flag, the version tag tells
+		Prerelease:  r.Upcoming || prereleaseTag(r.TagName),
+		PublishedAt: r.ReleasedAt,
+		URL:         r.Links.Self,
+	}
+	for _, l := range r.Assets.Links {
+		u := l.DirectAssetURL
+		if u == "" {
+			u = l.URL
+		}
+		rel.Assets = append(rel.Assets, Asset{ID: l.ID, Name: l.Name, URL: u})
+	}
+	return rel
+}
+
+func (g *GitLab) Repository(ctx context.Context, owner, name string) (r *Repository, err error) {
+	var project struct {
+		Description string `json:"description"`
+		License     *struct {
+			Key string `json:"key"`
+		} `json:"license"`
+	}
+	_, err = g.api.getJSON(ctx, gitlabProject(owner, name)+"?license=true", &project)
+	if err != nil {
+		return
+	}
+	r = &Repository{Description: project.Description}
+	if project.License != nil {
+		r.License = project.License.Key
+	}
+	return
+}
+
+func (g *GitLab) ListReleases(ctx context.Context, owner, name string) (releases []*Release, err error) {
+	for page := 1; ; page++ {
+		var rels []gitlabRelease
+		var resp *http.Response
+		resp, err = g.api.getJSON(ctx, fmt.Sprintf("%s/releases?per_page=100&page=%d", gitlabProject(owner, name), page), &rels)
+		if err != nil {
+			return
+		}
+		for i := range rels {
+			releases = append(releases, rels[i].convert())
+		}
+		if next, _ := strconv.Atoi(resp.Header.Get("X-Next-Page")); next == 0 || len(rels) == 0 {
+			return
+		}
+	}
+}
+
+func (g *GitLab) ReleaseByTag(ctx context.Context, owner, name, tag string) (r *Release, err error) {
+	var rel gitlabRelease
+	_, err = g.api.getJSON(ctx, gitlabProject(owner, name)+"/releases/"+url.PathEscape(tag), &rel)
+	if err != nil {
+		return
+	}
+	return rel.convert(), nil
+}
+
+func (g *GitLab) DownloadAsset(ctx context.Context, owner, name string, asset Asset) (io.ReadCloser, error) {
+	return g.api.download(ctx, asset.URL)
+}
+
+func (g *GitLab) PullRequest(ctx context.Context, owner, name string, number int) (p *PullRequest, err error) {
+	var mr struct {
+		IID      int      `json:"iid"`
+		Title    string   `json:"title"`
+		Desc     string   `json:"description"`
+		State    string   `json:"state"`
+		Labels   []string `json:"labels"`
+		SHA      string   `json:"sha"`
+		DiffRefs struct {
+			BaseSHA string `json:"base_sha"`
+		} `json:"diff_refs"`
+		CreatedAt time.Time  `json:"created_at"`
+		ClosedAt  *time.Time `json:"closed_at"`
+		MergedAt  *time.Time `json:"merged_at"`
+		WebURL    string     `json:"web_url"`
+	}
+	_, err = g.api.getJSON(ctx, fmt.Sprintf("%s/merge_requests/%d", gitlabProject(owner, name), number), &mr)
+	if err != nil {
+		return
+	}
+	p = &PullRequest{
+		Number:    mr.IID,
+		Title:     mr.Title,
+		Body:      mr.Desc,
+		State:     mr.State,
+		Merged:    mr.State == "merged",
+		Labels:    mr.Labels,
+		HeadSHA:   mr.SHA,
+		BaseSHA:   mr.DiffRefs.BaseSHA,
+		CreatedAt: mr.CreatedAt,
+		URL:       mr.WebURL,
+	}
+	if mr.MergedAt != nil {
+		p.ClosedAt = *mr.MergedAt
+	}
+	if mr.ClosedAt != nil {
+		p.ClosedAt = *mr.ClosedAt
+	}
+	return
+}
+
+type gitlabCommit struct {
+	ID           string    `json:"id"`
+	Message      string    `json:"message"`
+	AuthorName   string    `json:"author_name"`
+	AuthoredDate time.Time `json:"authored_date"`
+}
+
+func (c *gitlabCommit) convert() *Commit {
+	return &Commit{SHA: c.ID, Message: c.Message, Author: c.AuthorName, Date: c.AuthoredDate}
+}
+
+func (g *GitLab) PullRequestCommits(ctx context.Context, owner, name string, number int) (commits []*Commit, err error) {
+	for page := 1; ; page++ {
+		var cs []gitlabCommit
+		var resp *http.Response
+		resp, err = g.api.getJSON(ctx, fmt.Sprintf("%s/merge_requests/%d/commits?per_page=100&page=%d", gitlabProject(owner, name), number, page), &cs)
+		if err != nil {
+			return
+		}
+		for i := range cs {
+			commits = append(commits, cs[i].convert())
+		}
+		if next, _ := strconv.Atoi(resp.Header.Get("X-Next-Page")); next == 0 || len(cs) == 0 {
+			break
+		}
+	}
+	// GitLab lists the newest commits first
+	slices.Reverse(commits)
+	return
+}
+
+func (g *GitLab) Commit(ctx context.Context, owner, name, sha string) (c *Commit, err error) {
+	var commit gitlabCommit
+	_, err = g.api.getJSON(ctx, gitlabProject(owner, name)+"/repository/commits/"+url.PathEscape(sha), &commit)
+	if err != nil {
+		return
+	}
+	return commit.convert(), nil
+}
+
+// DownloadArtifact returns the artifacts archive of the job id
+func (g *GitLab) DownloadArtifact(ctx context.Context, owner, name string, id int64) (io.ReadCloser, error) {
+	return g.api.download(ctx, fmt.Sprintf("%s/jobs/%d/artifacts", gitlabProject(owner, name), id))
+}
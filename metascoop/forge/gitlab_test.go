@@ -0,0 +1,104 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitLabListReleases(t *testing.T) {
+	pages := map[string][]map[string]interface{}{
+		"1": {
+			{"tag_name": "v2.0.0", "description": "notes", "_links": map[string]string{"self": "https://gitlab.com/o/r/-/releases/v2.0.0"}},
+			// Tags without the v prefix are versions too
+			{"tag_name": "1.9.0-rc.1"},
+		},
+		"2": {
+			{"tag_name": "1.8.0", "assets": map[string]interface{}{"links": []map[string]interface{}{
+				{"id": 1, "name": "app.apk", "url": "https://gitlab.com/o/r/-/releases/1.8.0/downloads/app.apk"},
+				{"id": 2, "name": "app-x86.apk", "url": "https://example.com/other", "direct_asset_url": "https://example.com/direct"},
+			}}},
+			{"tag_name": "v1.8.0-beta", "upcoming_release": false},
+			{"tag_name": "nightly", "upcoming_release": true},
+		},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/o%2Fr/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("PRIVATE-TOKEN"); got != "secret" {
+			t.Errorf("PRIVATE-TOKEN %q", got)
+		}
+		page := r.URL.Query().Get("page")
+		if page == "1" {
+			w.Header().Set("X-Next-Page", "2")
+		}
+		_ = json.NewEncoder(w).Encode(pages[page])
+	}))
+	defer srv.Close()
+
+	releases, err := NewGitLab(srv.Client(), srv.URL, "secret").ListReleases(context.Background(), "o", "r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		tag        string
+		prerelease bool
+	}{
+		{"v2.0.0", false},
+		{"1.9.0-rc.1", true},
+		{"1.8.0", false},
+		{"v1.8.0-beta", true},
+		{"nightly", true},
+	}
+	if len(releases) != len(want) {
+		t.Fatalf("got %d releases, want %d", len(releases), len(want))
+	}
+	for i, w := range want {
+		if releases[i].TagName != w.tag || releases[i].Prerelease != w.prerelease {
+			t.Errorf("release %d: got %s (prerelease %v), want %s (prerelease %v)", i, releases[i].TagName, releases[i].Prerelease, w.tag, w.prerelease)
+		}
+	}
+	if r := releases[0]; r.Body != "notes" || r.URL != "https://gitlab.com/o/r/-/releases/v2.0.0" {
+		t.Errorf("got %+v", r)
+	}
+	assets := releases[2].Assets
+	if len(assets) != 2 || assets[0].URL != "https://gitlab.com/o/r/-/releases/1.8.0/downloads/app.apk" || assets[1].URL != "https://example.com/direct" {
+		t.Errorf("got assets %+v", assets)
+	}
+}
+
+func TestGitLabPullRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/o%2Fr/merge_requests/4":
+			_, _ = w.Write([]byte(`{"iid":4,"title":"Fix","state":"merged","labels":["fdroid"],"sha":"head",
+				"diff_refs":{"base_sha":"base"},"merged_at":"2024-01-02T03:04:05Z","web_url":"https://gitlab.com/o/r/-/merge_requests/4"}`))
+		case "/api/v4/projects/o%2Fr/merge_requests/4/commits":
+			_, _ = w.Write([]byte(`[{"id":"2","message":"Second"},{"id":"1","message":"First","author_name":"Name"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	g := NewGitLab(srv.Client(), srv.URL, "")
+
+	pr, err := g.PullRequest(context.Background(), "o", "r", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pr.Merged || pr.Open() || pr.HeadSHA != "head" || pr.BaseSHA != "base" || pr.ClosedAt.IsZero() || len(pr.Labels) != 1 {
+		t.Errorf("got %+v", pr)
+	}
+
+	commits, err := g.PullRequestCommits(context.Background(), "o", "r", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || commits[0].SHA != "1" || commits[0].Author != "Name" || commits[1].Subject() != "Second" {
+		t.Errorf("got %+v", commits)
+	}
+}
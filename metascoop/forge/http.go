@@ -0,0 +1,69 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// apiClient is a minimal JSON REST client shared by the GitLab and Gitea backends
+type apiClient struct {
+	client  *http.Client
+	baseURL string
+	// auth sets the credentials on requests to the API host
+	auth func(req *http.Request)
+}
+
+func (c *apiClient) newRequest(ctx context.Context, url string) (req *http.Request, err error) {
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return
+	}
+	if c.auth != nil && strings.HasPrefix(url, c.baseURL) {
+		c.auth(req)
+	}
+	return
+}
+
+// get fetches url, which may be relative to the base URL, and fails on non 2xx statuses
+func (c *apiClient) get(ctx context.Context, url string) (resp *http.Response, err error) {
+	if strings.HasPrefix(url, "/") {
+		url = c.baseURL + url
+	}
+	req, err := c.newRequest(ctx, url)
+	if err != nil {
+		return
+	}
+	resp, err = c.client.Do(req)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		_ = resp.Body.Close()
+		err = fmt.Errorf("GET %s: %s: %s", url, resp.Status, strings.TrimSpace(string(body)))
+		resp = nil
+	}
+	return
+}
+
+func (c *apiClient) getJSON(ctx context.Context, url string, v interface{}) (resp *http.Response, err error) {
+	resp, err = c.get(ctx, url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(v)
+	return
+}
+
+func (c *apiClient) download(ctx context.Context, url string) (rc io.ReadCloser, err error) {
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		return
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,179 @@
+package forge
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryTransport retries requests failing with transient errors, 5xx statuses or rate limits.
+// It honours Retry-After and X-RateLimit-Reset and otherwise backs off exponentially with jitter.
+type RetryTransport struct {
+	Base       http.RoundTripper
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxWait is the longest wait accepted for a rate limit reset, the response is returned past it
+	MaxWait time.Duration
+
+	mu            sync.Mutex
+	lastRemaining int
+}
+
+const maxRateLimitWait = 15 * time.Minute
+
+func NewRetryTransport(base http.RoundTripper) *RetryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RetryTransport{
+		Base:          base,
+		MaxRetries:    5,
+		MinBackoff:    time.Second,
+		MaxBackoff:    time.Minute,
+		MaxWait:       maxRateLimitWait,
+		lastRemaining: -1,
+	}
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	// Only replayable requests are retried
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return t.Base.RoundTrip(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			r = req.Clone(req.Context())
+			if r.Body, err = req.GetBody(); err != nil {
+				return
+			}
+		}
+
+		resp, err = t.Base.RoundTrip(r)
+		if resp != nil {
+			t.logQuota(resp)
+		}
+
+		wait, retry := t.shouldRetry(req.Context(), resp, err, attempt)
+		if !retry || attempt >= t.MaxRetries {
+			return
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			_ = resp.Body.Close()
+		}
+
+		if err != nil {
+			slog.WarnContext(req.Context(), "Request failed, retrying", "method", req.Method, "url", req.URL.Redacted(), "err", err, "wait", wait.Round(time.Millisecond))
+		} else {
+			slog.WarnContext(req.Context(), "Request failed, retrying", "method", req.Method, "url", req.URL.Redacted(), "status", resp.Status, "wait", wait.Round(time.Millisecond))
+		}
+		if err = sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// shouldRetry decides whether to retry and how long to wait before doing so
+func (t *RetryTransport) shouldRetry(ctx context.Context, resp *http.Response, err error, attempt int) (wait time.Duration, retry bool) {
+	if err != nil {
+		// Cancellation is final
+		if ctx.Err() != nil {
+			return 0, false
+		}
+		return t.backoff(attempt), true
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+	case resp.StatusCode == http.StatusForbidden && isRateLimited(resp):
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+	default:
+		return 0, false
+	}
+
+	if d, ok := retryAfter(resp); ok {
+		if d > t.MaxWait {
+			slog.WarnContext(ctx, "Rate limited, not waiting", "until", time.Now().Add(d).Format(time.RFC3339))
+			return 0, false
+		}
+		return d, true
+	}
+	return t.backoff(attempt), true
+}
+
+// backoff returns the exponential backoff of attempt with jitter in [d/2, d]
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	d := t.MinBackoff << attempt
+	if d <= 0 || d > t.MaxBackoff {
+		d = t.MaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func isRateLimited(resp *http.Response) bool {
+	if resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		return true
+	}
+	// Secondary rate limits are only told in the body
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return bytes.Contains(bytes.ToLower(body), []byte("rate limit"))
+}
+
+// retryAfter returns the wait asked by the server through Retry-After or X-RateLimit-Reset
+func retryAfter(resp *http.Response) (d time.Duration, ok bool) {
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			return max(time.Until(at), 0), true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			// A small margin for clock skew
+			return max(time.Until(time.Unix(reset, 0)), 0) + time.Second, true
+		}
+	}
+	return
+}
+
+// logQuota logs the remaining rate limit quota once, then whenever it runs low
+func (t *RetryTransport) logQuota(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.lastRemaining >= 0 && remaining >= t.lastRemaining {
+		t.lastRemaining = remaining
+		return
+	}
+	if t.lastRemaining < 0 || remaining < 100 || remaining/100 != t.lastRemaining/100 {
+		slog.InfoContext(resp.Request.Context(), "Rate limit", "host", resp.Request.URL.Host, "remaining", resp.Header.Get("X-RateLimit-Remaining"), "limit", resp.Header.Get("X-RateLimit-Limit"))
+	}
+	t.lastRemaining = remaining
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
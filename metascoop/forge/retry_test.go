@@ -0,0 +1,136 @@
+package forge
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// fail answers the failing attempts
+		fail     func(w http.ResponseWriter)
+		failures int
+		// wantStatus and wantRequests are the final status and the number of requests
+		wantStatus   int
+		wantRequests int
+		// minWait is the least time the retries should wait
+		minWait time.Duration
+	}{
+		{
+			name: "429 with Retry-After",
+			fail: func(w http.ResponseWriter) {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			failures:     1,
+			wantStatus:   http.StatusOK,
+			wantRequests: 2,
+			minWait:      time.Second,
+		},
+		{
+			name: "403 with an exhausted rate limit",
+			fail: func(w http.ResponseWriter) {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+				w.WriteHeader(http.StatusForbidden)
+			},
+			failures:     1,
+			wantStatus:   http.StatusOK,
+			wantRequests: 2,
+			minWait:      time.Second,
+		},
+		{
+			name: "secondary rate limit",
+			fail: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = io.WriteString(w, `{"message":"You have exceeded a secondary rate limit"}`)
+			},
+			failures:     1,
+			wantStatus:   http.StatusOK,
+			wantRequests: 2,
+		},
+		{
+			name: "rate limit reset past MaxWait",
+			fail: func(w http.ResponseWriter) {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+				w.WriteHeader(http.StatusForbidden)
+			},
+			failures:     1,
+			wantStatus:   http.StatusForbidden,
+			wantRequests: 1,
+		},
+		{
+			name:         "403 without rate limit",
+			fail:         func(w http.ResponseWriter) { w.WriteHeader(http.StatusForbidden) },
+			failures:     1,
+			wantStatus:   http.StatusForbidden,
+			wantRequests: 1,
+		},
+		{
+			name:         "gives up after max retries",
+			fail:         func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+			failures:     10,
+			wantStatus:   http.StatusServiceUnavailable,
+			wantRequests: 4,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests <= tc.failures {
+					tc.fail(w)
+					return
+				}
+				_, _ = io.WriteString(w, "ok")
+			}))
+			defer srv.Close()
+
+			rt := NewRetryTransport(http.DefaultTransport)
+			rt.MaxRetries = 3
+			rt.MinBackoff = time.Millisecond
+			rt.MaxBackoff = 2 * time.Millisecond
+			rt.MaxWait = time.Minute
+
+			start := time.Now()
+			resp, err := (&http.Client{Transport: rt}).Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus || requests != tc.wantRequests {
+				t.Errorf("got status %d after %d requests, want %d after %d", resp.StatusCode, requests, tc.wantStatus, tc.wantRequests)
+			}
+			if elapsed := time.Since(start); elapsed < tc.minWait {
+				t.Errorf("retried after %s, want at least %s", elapsed, tc.minWait)
+			}
+		})
+	}
+}
+
+func TestRetryAfterDate(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	d, ok := retryAfter(resp)
+	if !ok || d < 58*time.Second || d > time.Minute {
+		t.Errorf("got %s, %v, want about a minute", d, ok)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	rt := NewRetryTransport(nil)
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if d := rt.backoff(attempt); d < want/2 || d > want {
+			t.Errorf("attempt %d: got %s, want between %s and %s", attempt, d, want/2, want)
+		}
+	}
+	if d := rt.backoff(40); d < rt.MaxBackoff/2 || d > rt.MaxBackoff {
+		t.Errorf("got %s, want at most %s", d, rt.MaxBackoff)
+	}
+}
@@ -0,0 +1,137 @@
+package git
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Cache keeps shallow clones of repositories between runs, in a directory per URL.
+// Clones are partial, their blobs are fetched when checked out, and a lock file
+// next to each of them serializes the runs sharing the cache.
+type Cache struct {
+	// Dir holds the clones, every clone is temporary when empty
+	Dir string
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// repoDir returns the directory of the clone of url, readable and unique per URL
+func (c *Cache) repoDir(url string) string {
+	name := strings.TrimSuffix(url, ".git")
+	if i := strings.Index(name, "://"); i >= 0 {
+		name = name[i+3:]
+	}
+	name = strings.Trim(unsafeChars.ReplaceAllString(name, "_"), "_.")
+	if len(name) > 64 {
+		name = name[len(name)-64:]
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.Dir, fmt.Sprintf("%s-%x", name, sum[:8]))
+}
+
+func run(dir string, args ...string) (out []byte, err error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if out, err = cmd.Output(); err != nil {
+		err = fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return
+}
+
+// open locks the clone of url, initializing it when it isn't cached.
+// The returned func unlocks the clone, or removes it when the cache is disabled.
+func (c *Cache) open(url string) (dir string, release func(), err error) {
+	if c == nil || c.Dir == "" {
+		if dir, err = os.MkdirTemp("", "git-*"); err != nil {
+			return
+		}
+		release = func() { _ = os.RemoveAll(dir) }
+		if _, err = run(dir, "init", "--quiet"); err == nil {
+			_, err = run(dir, "remote", "add", "origin", url)
+		}
+		if err != nil {
+			release()
+		}
+		return
+	}
+
+	if err = os.MkdirAll(c.Dir, 0o755); err != nil {
+		return
+	}
+	dir = c.repoDir(url)
+	unlock, err := lock(dir + ".lock")
+	if err != nil {
+		return
+	}
+	release = unlock
+
+	if _, serr := os.Stat(filepath.Join(dir, ".git")); serr == nil {
+		return
+	}
+	// A clone interrupted by an earlier run is started over
+	if err = os.RemoveAll(dir); err == nil {
+		err = os.MkdirAll(dir, 0o755)
+	}
+	if err == nil {
+		_, err = run(dir, "init", "--quiet")
+	}
+	if err == nil {
+		_, err = run(dir, "remote", "add", "origin", url)
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		release()
+	}
+	return
+}
+
+// fetch fetches ref from origin without its history and blobs
+func fetch(dir, ref string) error {
+	_, err := run(dir, "fetch", "--quiet", "--depth=1", "--filter=blob:none", "--no-tags", "origin", ref)
+	return err
+}
+
+// Checkout fetches the default branch of url and checks out the files matching
+// paths, gitignore-like patterns, or the whole tree when paths is empty.
+// The checkout is locked until release is called.
+func (c *Cache) Checkout(url string, paths []string) (dir string, release func(), err error) {
+	dir, release, err = c.open(url)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
+	if err = fetch(dir, "HEAD"); err != nil {
+		return
+	}
+	if len(paths) == 0 {
+		_, err = run(dir, "sparse-checkout", "disable")
+	} else {
+		_, err = run(dir, append([]string{"sparse-checkout", "set", "--no-cone"}, paths...)...)
+	}
+	if err != nil {
+		return
+	}
+	if _, err = run(dir, "checkout", "--quiet", "--force", "--detach", "FETCH_HEAD"); err != nil {
+		return
+	}
+	if _, err = run(dir, "clean", "--quiet", "-ffdx"); err != nil {
+		return
+	}
+
+	slog.Info("Checked out", "url", url, "dir", dir)
+	return
+}
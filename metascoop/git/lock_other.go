@@ -0,0 +1,29 @@
+//go:build !unix
+
+package git
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// lock creates path as a lock file, waiting while another run holds it
+func lock(path string) (unlock func(), err error) {
+	for waiting := false; ; waiting = true {
+		var f *os.File
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return
+		}
+		if !waiting {
+			slog.Info("Waiting for another run to release the lock", "lock", path)
+		}
+		time.Sleep(time.Second)
+	}
+}
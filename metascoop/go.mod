@@ -11,9 +11,11 @@ require (
 	github.com/alecthomas/kong v0.9.0
 	github.com/google/go-github/v61 v61.0.0
 	github.com/hashicorp/go-version v1.6.0
+	golang.org/x/image v0.18.0
 	golang.org/x/mod v0.17.0
 	golang.org/x/oauth2 v0.20.0
-	golang.org/x/text v0.15.0
+	golang.org/x/text v0.16.0
+	software.sslmate.com/src/go-pkcs12 v0.4.0
 )
 
 require (
@@ -27,5 +29,5 @@ require (
 	github.com/mitchellh/reflectwalk v1.0.0 // indirect
 	github.com/shopspring/decimal v1.2.0 // indirect
 	github.com/spf13/cast v1.3.1 // indirect
-	golang.org/x/crypto v0.3.0 // indirect
+	golang.org/x/crypto v0.11.0 // indirect
 )
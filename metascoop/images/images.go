@@ -0,0 +1,187 @@
+package images
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Sizes from https://f-droid.org/docs/All_About_Descriptions_Graphics_and_Screenshots/
+const (
+	// IconSize is the recommended size of the square icon, larger ones are downscaled
+	IconSize = 512
+	// IconMinSize is the icon size of the highest density F-Droid generates
+	IconMinSize = 192
+
+	FeatureGraphicWidth  = 1024
+	FeatureGraphicHeight = 500
+
+	// DefaultMaxScreenshotSize is the longest side of screenshots unless configured
+	DefaultMaxScreenshotSize = 1920
+	// ScreenshotMinSize is the shortest side app stores accept
+	ScreenshotMinSize = 320
+	// maxAspectRatio is the ratio between the sides of screenshots app stores accept
+	maxAspectRatio = 2.0
+)
+
+// Result is a normalised image
+type Result struct {
+	Data []byte
+	// Ext is the extension of Data, a downscaled WebP becomes a PNG
+	Ext    string
+	Width  int
+	Height int
+	// Violations are the requirements the image doesn't meet
+	Violations []string
+}
+
+// Hash returns the SHA-256 of the data, as in the repo index
+func (r Result) Hash() string {
+	return fmt.Sprintf("%x", sha256.Sum256(r.Data))
+}
+
+type source struct {
+	img    image.Image
+	format string
+	raw    []byte
+}
+
+func decode(path string) (s source, err error) {
+	if s.raw, err = os.ReadFile(path); err != nil {
+		return
+	}
+	if s.img, s.format, err = image.Decode(bytes.NewReader(s.raw)); err != nil {
+		err = fmt.Errorf("decoding %q: %w", path, err)
+	}
+	return
+}
+
+// fit returns the size of a w×h image scaled down to fit in maxW×maxH
+func fit(w, h, maxW, maxH int) (int, int) {
+	if w <= maxW && h <= maxH {
+		return w, h
+	}
+	scale := math.Min(float64(maxW)/float64(w), float64(maxH)/float64(h))
+	return max(1, int(math.Round(float64(w)*scale))), max(1, int(math.Round(float64(h)*scale)))
+}
+
+func scale(img image.Image, w, h int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encode writes the image in the format of s, as PNG when asPNG is set or when there is no encoder for it.
+// An image that wasn't resized keeps its original bytes unless re-encoding makes it smaller.
+func (s source) encode(img image.Image, resized, asPNG bool) (data []byte, ext string, err error) {
+	if s.format == "jpeg" && !asPNG {
+		var buf bytes.Buffer
+		if err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			return
+		}
+		data = buf.Bytes()
+		if !resized && len(s.raw) <= len(data) {
+			data = s.raw
+		}
+		return data, ".jpg", nil
+	}
+	if s.format == "webp" && !resized && !asPNG {
+		return s.raw, ".webp", nil
+	}
+	if data, err = encodePNG(img); err != nil {
+		return
+	}
+	if s.format == "png" && !resized && len(s.raw) <= len(data) {
+		data = s.raw
+	}
+	return data, ".png", nil
+}
+
+// Icon normalises the icon at path into a PNG of at most IconSize
+func Icon(path string) (r Result, err error) {
+	s, err := decode(path)
+	if err != nil {
+		return
+	}
+	return s.icon()
+}
+
+// IconImage normalises a decoded icon, such as the launcher icon of an APK, into a PNG
+func IconImage(img image.Image) (Result, error) {
+	return source{img: img}.icon()
+}
+
+func (s source) icon() (r Result, err error) {
+	b := s.img.Bounds()
+	if b.Dx() != b.Dy() {
+		r.Violations = append(r.Violations, fmt.Sprintf("icon is %dx%d, it should be square", b.Dx(), b.Dy()))
+	}
+	if min(b.Dx(), b.Dy()) < IconMinSize {
+		r.Violations = append(r.Violations, fmt.Sprintf("icon is %dx%d, it should be at least %dx%d", b.Dx(), b.Dy(), IconMinSize, IconMinSize))
+	}
+	return s.normalise(r, IconSize, IconSize, true)
+}
+
+// FeatureGraphic normalises the feature graphic at path to at most FeatureGraphicWidth wide
+func FeatureGraphic(path string) (r Result, err error) {
+	s, err := decode(path)
+	if err != nil {
+		return
+	}
+	b := s.img.Bounds()
+	want := float64(FeatureGraphicWidth) / FeatureGraphicHeight
+	if ratio := float64(b.Dx()) / float64(b.Dy()); math.Abs(ratio-want) > 0.01*want {
+		r.Violations = append(r.Violations, fmt.Sprintf("feature graphic is %dx%d, it should be %dx%d", b.Dx(), b.Dy(), FeatureGraphicWidth, FeatureGraphicHeight))
+	}
+	return s.normalise(r, FeatureGraphicWidth, math.MaxInt, false)
+}
+
+// Screenshot normalises the screenshot at path so its longest side is at most maxSize
+func Screenshot(path string, maxSize int) (r Result, err error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxScreenshotSize
+	}
+	s, err := decode(path)
+	if err != nil {
+		return
+	}
+	b := s.img.Bounds()
+	short, long := min(b.Dx(), b.Dy()), max(b.Dx(), b.Dy())
+	if float64(long) > maxAspectRatio*float64(short) {
+		r.Violations = append(r.Violations, fmt.Sprintf("screenshot is %dx%d, its long side should be at most %g times its short side", b.Dx(), b.Dy(), maxAspectRatio))
+	}
+	if short < ScreenshotMinSize {
+		r.Violations = append(r.Violations, fmt.Sprintf("screenshot is %dx%d, its short side should be at least %d", b.Dx(), b.Dy(), ScreenshotMinSize))
+	}
+	return s.normalise(r, maxSize, maxSize, false)
+}
+
+func (s source) normalise(r Result, maxW, maxH int, asPNG bool) (Result, error) {
+	b := s.img.Bounds()
+	r.Width, r.Height = fit(b.Dx(), b.Dy(), maxW, maxH)
+	img := s.img
+	resized := r.Width != b.Dx() || r.Height != b.Dy()
+	if resized {
+		img = scale(img, r.Width, r.Height)
+	}
+	var err error
+	r.Data, r.Ext, err = s.encode(img, resized, asPNG)
+	return r, err
+}
@@ -0,0 +1,55 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeJPEG(t *testing.T, quality int) (path string, raw []byte) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 640, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 640; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), uint8(x ^ y), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(t.TempDir(), "screenshot.jpg")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path, buf.Bytes()
+}
+
+func TestScreenshotKeepsTheSmallerJPEG(t *testing.T) {
+	for _, tc := range []struct {
+		quality int
+		keepRaw bool
+	}{
+		{100, false},
+		{50, true},
+	} {
+		path, raw := writeJPEG(t, tc.quality)
+		r, err := Screenshot(path, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Ext != ".jpg" || r.Width != 640 || r.Height != 400 {
+			t.Errorf("quality %d: got %s %dx%d, want .jpg 640x400", tc.quality, r.Ext, r.Width, r.Height)
+		}
+		if kept := bytes.Equal(r.Data, raw); kept != tc.keepRaw {
+			t.Errorf("quality %d: kept the original %v, want %v", tc.quality, kept, tc.keepRaw)
+		}
+		if len(r.Data) > len(raw) {
+			t.Errorf("quality %d: got %d bytes, more than the original %d", tc.quality, len(r.Data), len(raw))
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Buffer holds the records logged with its context until they are flushed,
+// so that concurrent jobs can write their logs in their own group
+type Buffer struct {
+	mu      sync.Mutex
+	records []bufferedRecord
+}
+
+type bufferedRecord struct {
+	h slog.Handler
+	r slog.Record
+}
+
+type bufferKey struct{}
+
+// WithBuffer returns a context whose records are kept in the returned buffer instead of being written
+func WithBuffer(ctx context.Context) (context.Context, *Buffer) {
+	b := &Buffer{}
+	return context.WithValue(ctx, bufferKey{}, b), b
+}
+
+// buffer keeps r in the buffer of ctx, if any, to be written by h later
+func buffer(ctx context.Context, h slog.Handler, r slog.Record) bool {
+	b, ok := ctx.Value(bufferKey{}).(*Buffer)
+	if !ok {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records = append(b.records, bufferedRecord{h: h, r: r.Clone()})
+	return true
+}
+
+// Flush writes the buffered records in the order they were logged and empties the buffer
+func (b *Buffer) Flush() (err error) {
+	b.mu.Lock()
+	records := b.records
+	b.records = nil
+	b.mu.Unlock()
+	for _, rec := range records {
+		if herr := rec.h.Handle(context.Background(), rec.r); herr != nil && err == nil {
+			err = herr
+		}
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestBufferFlushesInGroup(t *testing.T) {
+	var out bytes.Buffer
+	h, err := NewHandler(FormatPlain, &out, slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(h))
+
+	ctxA, a := WithBuffer(context.Background())
+	ctxB, b := WithBuffer(context.Background())
+	logger := slog.New(h).With("job", "x")
+	slog.InfoContext(ctxA, "a1")
+	logger.InfoContext(ctxB, "b1")
+	slog.Info("unbuffered")
+	slog.WarnContext(ctxA, "a2")
+
+	for _, group := range []struct {
+		name string
+		buf  *Buffer
+	}{{"B", b}, {"A", a}} {
+		end := Group(group.name)
+		if err := group.buf.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		end()
+	}
+	// Flushing empties the buffer
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		// Drop the timestamps
+		if !strings.HasPrefix(line, "==>") {
+			line = line[len("2006/01/02 15:04:05 "):]
+		}
+		lines = append(lines, line)
+	}
+	want := []string{"unbuffered", "==> B", "b1 job=x", "==> A", "a1", "WARN a2"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// jsonHandler writes JSON lines, the open groups go in the group attribute
+type jsonHandler struct {
+	slog.Handler
+	out *output
+}
+
+func (h *jsonHandler) Handle(ctx context.Context, r slog.Record) error {
+	if buffer(ctx, h, r) {
+		return nil
+	}
+	h.out.mu.Lock()
+	group := strings.Join(h.out.groups, "/")
+	h.out.mu.Unlock()
+	if group != "" {
+		r = r.Clone()
+		r.AddAttrs(slog.String("group", group))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *jsonHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &jsonHandler{Handler: h.Handler.WithAttrs(attrs), out: h.out}
+}
+
+func (h *jsonHandler) WithGroup(name string) slog.Handler {
+	return &jsonHandler{Handler: h.Handler.WithGroup(name), out: h.out}
+}
+
+func (h *jsonHandler) startGroup(name string) {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	h.out.groups = append(h.out.groups, name)
+}
+
+func (h *jsonHandler) endGroup() {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	if len(h.out.groups) != 0 {
+		h.out.groups = h.out.groups[:len(h.out.groups)-1]
+	}
+}
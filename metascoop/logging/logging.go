@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Formats of the log output
+const (
+	FormatAuto   = "auto"
+	FormatGitHub = "github"
+	FormatGitLab = "gitlab"
+	FormatPlain  = "plain"
+	FormatJSON   = "json"
+)
+
+// Attribute keys the CI formats render as a source location
+const (
+	KeyFile   = "file"
+	KeyLine   = "line"
+	KeyColumn = "col"
+)
+
+// Detect returns the format of the CI the process runs in, plain otherwise
+func Detect() string {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return FormatGitHub
+	case os.Getenv("GITLAB_CI") == "true":
+		return FormatGitLab
+	}
+	return FormatPlain
+}
+
+// NewHandler returns a handler writing records to w in format
+func NewHandler(format string, w io.Writer, level slog.Leveler) (h slog.Handler, err error) {
+	if format == FormatAuto || format == "" {
+		format = Detect()
+	}
+	out := &output{w: w}
+	switch format {
+	case FormatGitHub, FormatGitLab, FormatPlain:
+		h = &textHandler{out: out, format: format, level: level}
+	case FormatJSON:
+		h = &jsonHandler{Handler: slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}), out: out}
+	default:
+		err = fmt.Errorf("unknown log format %q", format)
+	}
+	return
+}
+
+// Setup makes the handler of format the default one, the log package included
+func Setup(format string, w io.Writer) error {
+	h, err := NewHandler(format, w, slog.LevelInfo)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// Group opens a collapsible section of the log, the returned func closes it
+func Group(name string) (end func()) {
+	g, ok := slog.Default().Handler().(grouper)
+	if !ok {
+		slog.Info(name)
+		return func() {}
+	}
+	g.startGroup(name)
+	return g.endGroup
+}
+
+type grouper interface {
+	startGroup(name string)
+	endGroup()
+}
+
+// output is shared by a handler and the ones derived from it
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+	// groups holds the names of the open groups
+	groups []string
+	// ids are the GitLab section ids of the open groups
+	ids   []string
+	count int
+}
@@ -0,0 +1,239 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// textHandler writes one line per record, with the groups and annotations of its CI format
+type textHandler struct {
+	out    *output
+	format string
+	level  slog.Leveler
+	attrs  []slog.Attr
+	prefix string
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = slices.Clip(h.attrs)
+	for _, a := range attrs {
+		h2.attrs = append(h2.attrs, slog.Attr{Key: h.prefix + a.Key, Value: a.Value})
+	}
+	return &h2
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// location is the source location carried by the file, line and col attributes
+type location struct {
+	file, line, col string
+}
+
+func (l location) String() string {
+	s := l.file
+	for _, p := range []string{l.line, l.col} {
+		if p == "" {
+			break
+		}
+		s += ":" + p
+	}
+	return s
+}
+
+func (h *textHandler) Handle(ctx context.Context, r slog.Record) error {
+	if buffer(ctx, h, r) {
+		return nil
+	}
+	var loc location
+	var sb strings.Builder
+	sb.WriteString(r.Message)
+	for _, a := range h.attrs {
+		appendAttr(&sb, &loc, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&sb, &loc, h.prefix, a)
+		return true
+	})
+	msg := sb.String()
+
+	var line string
+	if h.format == FormatGitHub {
+		line = githubLine(r.Level, loc, msg)
+	} else {
+		if loc.file != "" {
+			msg = loc.String() + ": " + msg
+		}
+		level := levelPrefix(r.Level)
+		if h.format == FormatGitLab && level != "" {
+			level = levelColor(r.Level) + level + "\x1b[0m"
+		}
+		line = level + msg
+		if !r.Time.IsZero() {
+			line = r.Time.Format("2006/01/02 15:04:05") + " " + line
+		}
+	}
+
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	_, err := fmt.Fprintln(h.out.w, line)
+	return err
+}
+
+func appendAttr(sb *strings.Builder, loc *location, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			appendAttr(sb, loc, prefix, ga)
+		}
+		return
+	}
+	key := prefix + a.Key
+	switch key {
+	case KeyFile:
+		loc.file = a.Value.String()
+		return
+	case KeyLine:
+		loc.line = a.Value.String()
+		return
+	case KeyColumn:
+		loc.col = a.Value.String()
+		return
+	}
+	v := a.Value.String()
+	if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+		v = strconv.Quote(v)
+	}
+	fmt.Fprintf(sb, " %s=%s", key, v)
+}
+
+func levelPrefix(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return "ERROR "
+	case l >= slog.LevelWarn:
+		return "WARN "
+	case l < slog.LevelInfo:
+		return "DEBUG "
+	}
+	return ""
+}
+
+func levelColor(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return "\x1b[31m"
+	case l >= slog.LevelWarn:
+		return "\x1b[33m"
+	}
+	return "\x1b[2m"
+}
+
+// githubLine turns warnings and errors into workflow command annotations,
+// see https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions
+func githubLine(l slog.Level, loc location, msg string) string {
+	var cmd string
+	switch {
+	case l >= slog.LevelError:
+		cmd = "error"
+	case l >= slog.LevelWarn:
+		cmd = "warning"
+	case l < slog.LevelInfo:
+		cmd = "debug"
+	default:
+		if loc.file != "" {
+			msg = loc.String() + ": " + msg
+		}
+		return msg
+	}
+	// Annotations are only attached to files of the workspace
+	if wd, err := os.Getwd(); err == nil && filepath.IsAbs(loc.file) {
+		if rel, err := filepath.Rel(wd, loc.file); err == nil && !strings.HasPrefix(rel, "..") {
+			loc.file = rel
+		}
+	}
+	var props []string
+	for _, p := range [][2]string{{"file", loc.file}, {"line", loc.line}, {"col", loc.col}} {
+		if p[1] != "" {
+			props = append(props, p[0]+"="+githubEscape(p[1], true))
+		}
+	}
+	if len(props) != 0 {
+		cmd += " " + strings.Join(props, ",")
+	}
+	return "::" + cmd + "::" + githubEscape(msg, false)
+}
+
+func githubEscape(s string, property bool) string {
+	s = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+	if property {
+		s = strings.NewReplacer(":", "%3A", ",", "%2C").Replace(s)
+	}
+	return s
+}
+
+func (h *textHandler) startGroup(name string) {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	name = strings.ReplaceAll(name, "\n", " ")
+	switch h.format {
+	case FormatGitHub:
+		// Actions doesn't nest groups, the inner ones are plain lines
+		if len(h.out.groups) == 0 {
+			fmt.Fprintf(h.out.w, "::group::%s\n", name)
+		} else {
+			fmt.Fprintln(h.out.w, name)
+		}
+	case FormatGitLab:
+		h.out.count++
+		id := fmt.Sprintf("metascoop_%d", h.out.count)
+		h.out.ids = append(h.out.ids, id)
+		fmt.Fprintf(h.out.w, "\x1b[0Ksection_start:%d:%s[collapsed=true]\r\x1b[0K%s\n", time.Now().Unix(), id, name)
+	default:
+		fmt.Fprintf(h.out.w, "%s==> %s\n", strings.Repeat("  ", len(h.out.groups)), name)
+	}
+	h.out.groups = append(h.out.groups, name)
+}
+
+func (h *textHandler) endGroup() {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	if len(h.out.groups) == 0 {
+		return
+	}
+	h.out.groups = h.out.groups[:len(h.out.groups)-1]
+	switch h.format {
+	case FormatGitHub:
+		if len(h.out.groups) == 0 {
+			fmt.Fprintln(h.out.w, "::endgroup::")
+		}
+	case FormatGitLab:
+		id := h.out.ids[len(h.out.ids)-1]
+		h.out.ids = h.out.ids[:len(h.out.ids)-1]
+		fmt.Fprintf(h.out.w, "\x1b[0Ksection_end:%d:%s\r\x1b[0K\n", time.Now().Unix(), id)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"metascoop/apps"
+	"metascoop/plan"
 	"os"
 	"path/filepath"
 	"text/template"
@@ -19,24 +20,48 @@ const (
 	tableTmpl = `
 | Icon | Name | Description | Version |
 | --- | --- | --- | --- |{{range .Apps}}
-| <a href="{{.sourceCode}}"><img src="fdroid/repo/{{.packageName}}/en-US/icon.png" alt="{{.name}} icon" width="36px" height="36px"></a> | [**{{.name}}**]({{.sourceCode}}) | {{.summary | replace "\n" "<br />"}} | {{.suggestedVersionName}} |{{end}}
+| <a href="{{.sourceCode}}"><img src="{{icon .packageName}}" alt="{{localized . "name"}} icon" width="36px" height="36px"></a> | [**{{localized . "name"}}**]({{.sourceCode}}) | {{localized . "summary" | replace "\n" "<br />"}} | {{.suggestedVersionName}} |{{end}}
 ` + tableEnd
 )
 
-var tmpl = template.Must(template.New("").Funcs(sprig.FuncMap()).Parse(tableTmpl))
+var tmpl = template.Must(template.New("").Funcs(sprig.FuncMap()).Funcs(localeFuncs("", "")).Parse(tableTmpl))
+
+// localeFuncs returns the template functions picking the texts and icons of locale
+func localeFuncs(repoDir, locale string) template.FuncMap {
+	return template.FuncMap{
+		"localized": func(app map[string]interface{}, key string) interface{} {
+			if l, ok := app["localized"].(map[string]interface{}); ok {
+				if texts, ok := l[locale].(map[string]interface{}); ok {
+					if v, ok := texts[key].(string); ok && v != "" {
+						return v
+					}
+				}
+			}
+			return app[key]
+		},
+		"icon": func(packageName string) string {
+			dir := locale
+			if _, err := os.Stat(filepath.Join(repoDir, packageName, dir, "icon.png")); err != nil {
+				dir = apps.DefaultLocale
+			}
+			return fmt.Sprintf("fdroid/repo/%s/%s/icon.png", packageName, dir)
+		},
+	}
+}
 
-func RegenerateReadme(repoDir string) (err error) {
+// RegenerateReadme rewrites the apps table of the README with the texts of locale,
+// falling back to the default locale
+func RegenerateReadme(p *plan.Plan, repoDir, locale string) (err error) {
 	readmePath := filepath.Join(filepath.Dir(filepath.Dir(repoDir)), "README.md")
 	content, err := os.ReadFile(readmePath)
 	if err != nil {
 		return
 	}
 
-	fdroidIndexFilePath := filepath.Join(repoDir, "index-v1.json")
 	var index *apps.RepoIndex
-	index, err = apps.ReadIndex(fdroidIndexFilePath)
+	index, err = apps.LoadIndex(repoDir)
 	if err != nil {
-		err = fmt.Errorf("reading f-droid repo index: %s\n::endgroup::\n", err.Error())
+		err = fmt.Errorf("reading f-droid repo index: %w", err)
 		return
 	}
 
@@ -54,16 +79,26 @@ func RegenerateReadme(repoDir string) (err error) {
 
 	table.WriteString(tableStart)
 
-	err = tmpl.Execute(&table, index)
+	t, err := tmpl.Clone()
 	if err != nil {
 		return err
 	}
+	err = t.Funcs(localeFuncs(repoDir, locale)).Execute(&table, index)
+	if err != nil {
+		return err
+	}
+
+	// The template ends with the table end marker, drop the old ones
+	rest := content[tableEndIndex:]
+	for bytes.HasPrefix(rest, []byte(tableEnd)) {
+		rest = rest[len(tableEnd):]
+	}
 
 	newContent := []byte{}
 
 	newContent = append(newContent, content[:tableStartIndex]...)
 	newContent = append(newContent, table.Bytes()...)
-	newContent = append(newContent, content[tableEndIndex:]...)
+	newContent = append(newContent, rest...)
 
-	return os.WriteFile(readmePath, newContent, os.ModePerm)
+	return p.WriteFile(readmePath, newContent)
 }
@@ -0,0 +1,186 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"metascoop/file"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Operations of an Action
+const (
+	OpDownload = "download"
+	OpWrite    = "write"
+	OpRemove   = "remove"
+	OpMove     = "move"
+	OpRun      = "run"
+	OpClone    = "clone"
+	OpSign     = "sign"
+)
+
+// Action is a change to the tree that a dry run skipped
+type Action struct {
+	Op     string `json:"op"`
+	Path   string `json:"path,omitempty"`
+	Target string `json:"target,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	// Diff is the unified diff of a file write
+	Diff string `json:"diff,omitempty"`
+}
+
+// Plan performs changes to the tree, or only records them in a dry run.
+// A nil Plan performs every change.
+type Plan struct {
+	dryRun  bool
+	mu      sync.Mutex
+	actions []Action
+}
+
+func New(dryRun bool) *Plan {
+	return &Plan{dryRun: dryRun}
+}
+
+func (p *Plan) DryRun() bool {
+	return p != nil && p.dryRun
+}
+
+// Record adds an action to the plan, it is a no-op outside of dry runs
+func (p *Plan) Record(a Action) {
+	if !p.DryRun() {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.actions = append(p.actions, a)
+}
+
+func (p *Plan) Actions() []Action {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Action(nil), p.actions...)
+}
+
+// WriteFile atomically replaces the content of path, creating its directory
+func (p *Plan) WriteFile(path string, data []byte) (err error) {
+	if p.DryRun() {
+		old, rerr := os.ReadFile(path)
+		if rerr != nil && !errors.Is(rerr, fs.ErrNotExist) {
+			return rerr
+		}
+		if rerr == nil && string(old) == string(data) {
+			return
+		}
+		detail := "update"
+		if rerr != nil {
+			detail = "create"
+		}
+		if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+			p.Record(Action{Op: OpWrite, Path: path, Detail: fmt.Sprintf("%s, %d bytes", detail, len(data))})
+			return
+		}
+		p.Record(Action{Op: OpWrite, Path: path, Detail: detail, Diff: Diff(path, string(old), string(data))})
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return
+	}
+	tmpPath := path + ".tmp"
+	if err = os.WriteFile(tmpPath, data, 0o644); err != nil {
+		_ = os.Remove(tmpPath)
+		return
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Remove removes the file at path
+func (p *Plan) Remove(path string) error {
+	if !p.DryRun() {
+		return os.Remove(path)
+	}
+	if _, err := os.Lstat(path); err != nil {
+		return err
+	}
+	p.Record(Action{Op: OpRemove, Path: path})
+	return nil
+}
+
+// RemoveAll removes path and its children, nothing happens when it doesn't exist
+func (p *Plan) RemoveAll(path string) error {
+	if !p.DryRun() {
+		return os.RemoveAll(path)
+	}
+	if _, err := os.Lstat(path); err == nil {
+		p.Record(Action{Op: OpRemove, Path: path})
+	}
+	return nil
+}
+
+// Move moves oldpath to newpath, creating the directory of newpath
+func (p *Plan) Move(oldpath, newpath string) error {
+	if !p.DryRun() {
+		if err := os.MkdirAll(filepath.Dir(newpath), os.ModePerm); err != nil {
+			return err
+		}
+		return file.Move(oldpath, newpath)
+	}
+	if _, err := os.Lstat(oldpath); err != nil {
+		return err
+	}
+	p.Record(Action{Op: OpMove, Path: oldpath, Target: newpath})
+	return nil
+}
+
+// Run runs cmd
+func (p *Plan) Run(cmd *exec.Cmd) error {
+	if !p.DryRun() {
+		return cmd.Run()
+	}
+	p.Record(Action{Op: OpRun, Path: cmd.Dir, Detail: strings.Join(cmd.Args, " ")})
+	return nil
+}
+
+// WriteText prints the plan for humans, with the diffs of the written files
+func (p *Plan) WriteText(w io.Writer) (err error) {
+	actions := p.Actions()
+	if len(actions) == 0 {
+		_, err = fmt.Fprintln(w, "No changes")
+		return
+	}
+	for _, a := range actions {
+		line := fmt.Sprintf("%-8s %s", a.Op, a.Path)
+		if a.Target != "" {
+			line += " -> " + a.Target
+		}
+		if a.Detail != "" {
+			line += " (" + a.Detail + ")"
+		}
+		if _, err = fmt.Fprintln(w, line); err != nil {
+			return
+		}
+		if a.Diff != "" {
+			if _, err = fmt.Fprint(w, a.Diff); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (p *Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p.Actions())
+}
@@ -0,0 +1,87 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Key is a signing key with its certificate
+type Key struct {
+	PrivateKey  crypto.Signer
+	Certificate *x509.Certificate
+}
+
+// LoadPKCS12 reads the key entry named alias of a PKCS#12 keystore, trying each password in turn.
+// The alias may be empty when the keystore holds a single key.
+func LoadPKCS12(path, alias string, passwords ...string) (key *Key, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	// DecodeChain refuses keystores with several keys, ToPEM keeps every entry with its alias
+	var blocks []*pem.Block
+	err = errors.New("no password given")
+	for _, password := range passwords {
+		blocks, err = pkcs12.ToPEM(data, password)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("decoding keystore %q: %w", path, err)
+		return
+	}
+
+	var keyBlock *pem.Block
+	var aliases []string
+	for _, b := range blocks {
+		if b.Type != "PRIVATE KEY" {
+			continue
+		}
+		name := b.Headers["friendlyName"]
+		aliases = append(aliases, name)
+		// keytool lowercases the aliases
+		if keyBlock == nil && (alias == "" || strings.EqualFold(name, alias)) {
+			keyBlock = b
+		}
+	}
+	switch {
+	case len(aliases) == 0:
+		return nil, fmt.Errorf("no key in keystore %q", path)
+	case keyBlock == nil:
+		return nil, fmt.Errorf("no key %q in keystore %q, its keys are %s", alias, path, strings.Join(aliases, ", "))
+	case alias == "" && len(aliases) > 1:
+		return nil, fmt.Errorf("keystore %q has several keys (%s), set repo_keyalias", path, strings.Join(aliases, ", "))
+	}
+
+	var cert *x509.Certificate
+	for _, b := range blocks {
+		if b.Type != "CERTIFICATE" || b.Headers["localKeyId"] != keyBlock.Headers["localKeyId"] {
+			continue
+		}
+		if cert, err = x509.ParseCertificate(b.Bytes); err != nil {
+			return nil, fmt.Errorf("decoding certificate of %q in keystore %q: %w", keyBlock.Headers["friendlyName"], path, err)
+		}
+		break
+	}
+	if cert == nil {
+		return nil, fmt.Errorf("no certificate for %q in keystore %q", keyBlock.Headers["friendlyName"], path)
+	}
+
+	// ToPEM encodes RSA keys as PKCS#1 and EC ones as SEC 1
+	if k, kerr := x509.ParsePKCS1PrivateKey(keyBlock.Bytes); kerr == nil {
+		return &Key{PrivateKey: k, Certificate: cert}, nil
+	}
+	if k, kerr := x509.ParseECPrivateKey(keyBlock.Bytes); kerr == nil {
+		return &Key{PrivateKey: k, Certificate: cert}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type of %q in keystore %q", keyBlock.Headers["friendlyName"], path)
+}
@@ -0,0 +1,57 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"strings"
+	"testing"
+)
+
+// testdata/keystore.p12 holds an EC key aliased "other" followed by an RSA one aliased "repokey",
+// both with the password "fixture"
+
+func TestLoadPKCS12(t *testing.T) {
+	for _, tc := range []struct {
+		alias string
+		cn    string
+		rsa   bool
+	}{
+		{"repokey", "repokey", true},
+		{"RepoKey", "repokey", true},
+		{"other", "other", false},
+	} {
+		key, err := LoadPKCS12("testdata/keystore.p12", tc.alias, "wrong", "fixture")
+		if err != nil {
+			t.Errorf("%s: %v", tc.alias, err)
+			continue
+		}
+		if key.Certificate.Subject.CommonName != tc.cn {
+			t.Errorf("%s: got the certificate of %q", tc.alias, key.Certificate.Subject.CommonName)
+		}
+		switch k := key.PrivateKey.(type) {
+		case *rsa.PrivateKey:
+			if !tc.rsa || !k.PublicKey.Equal(key.Certificate.PublicKey) {
+				t.Errorf("%s: RSA key doesn't match", tc.alias)
+			}
+		case *ecdsa.PrivateKey:
+			if tc.rsa || !k.PublicKey.Equal(key.Certificate.PublicKey) {
+				t.Errorf("%s: EC key doesn't match", tc.alias)
+			}
+		}
+	}
+}
+
+func TestLoadPKCS12Errors(t *testing.T) {
+	for _, tc := range []struct {
+		alias, password, err string
+	}{
+		{"missing", "fixture", `no key "missing"`},
+		{"", "fixture", "several keys"},
+		{"repokey", "wrong", "decoding keystore"},
+	} {
+		_, err := LoadPKCS12("testdata/keystore.p12", tc.alias, tc.password)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("alias %q, password %q: got error %v, want %q", tc.alias, tc.password, err, tc.err)
+		}
+	}
+}
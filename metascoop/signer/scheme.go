@@ -0,0 +1,138 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	sigRSAPSSSHA256   = 0x0101
+	sigRSAPSSSHA512   = 0x0102
+	sigRSAPKCS1SHA256 = 0x0103
+	sigRSAPKCS1SHA512 = 0x0104
+	sigECDSASHA256    = 0x0201
+	sigECDSASHA512    = 0x0202
+
+	BlockIDV2 = 0x7109871a
+	BlockIDV3 = 0xf05368c0
+
+	attrStrippingProtection = 0xbeeff00d
+
+	SigningBlockMagic = "APK Sig Block 42"
+
+	chunkSize = 1 << 20
+
+	// v3 signatures are only checked from Android 9
+	v3MinSdk = 28
+	v3MaxSdk = 0x7fffffff
+)
+
+func u32(v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, v)
+}
+
+func lengthPrefixed(b []byte) []byte {
+	return append(u32(uint32(len(b))), b...)
+}
+
+func sequence(items ...[]byte) []byte {
+	var b []byte
+	for _, item := range items {
+		b = append(b, lengthPrefixed(item)...)
+	}
+	return lengthPrefixed(b)
+}
+
+// contentDigest computes the CHUNKED_SHA256 or CHUNKED_SHA512 digest of the sections covered by the signing block
+func contentDigest(hash crypto.Hash, sections ...[]byte) []byte {
+	var count uint32
+	var digests []byte
+	for _, section := range sections {
+		for off := 0; off < len(section); off += chunkSize {
+			chunk := section[off:min(off+chunkSize, len(section))]
+			h := hash.New()
+			h.Write([]byte{0xa5})
+			h.Write(u32(uint32(len(chunk))))
+			h.Write(chunk)
+			digests = h.Sum(digests)
+			count++
+		}
+	}
+	h := hash.New()
+	h.Write([]byte{0x5a})
+	h.Write(u32(count))
+	h.Write(digests)
+	return h.Sum(nil)
+}
+
+func signatureAlgorithm(key *Key) (uint32, error) {
+	switch key.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		return sigRSAPKCS1SHA256, nil
+	case *ecdsa.PrivateKey:
+		return sigECDSASHA256, nil
+	}
+	return 0, fmt.Errorf("unsupported key type %T", key.PrivateKey)
+}
+
+// signerBlock builds the signer of a v2 (v3 == false) or v3 signature scheme block
+func signerBlock(key *Key, digest []byte, v3 bool, attrs ...[]byte) (block []byte, err error) {
+	alg, err := signatureAlgorithm(key)
+	if err != nil {
+		return
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(key.PrivateKey.Public())
+	if err != nil {
+		return
+	}
+
+	signedData := sequence(append(u32(alg), lengthPrefixed(digest)...))
+	signedData = append(signedData, sequence(key.Certificate.Raw)...)
+	if v3 {
+		signedData = append(signedData, u32(v3MinSdk)...)
+		signedData = append(signedData, u32(v3MaxSdk)...)
+	}
+	signedData = append(signedData, sequence(attrs...)...)
+
+	sum := sha256.Sum256(signedData)
+	signature, err := key.PrivateKey.Sign(rand.Reader, sum[:], crypto.SHA256)
+	if err != nil {
+		return
+	}
+
+	block = lengthPrefixed(signedData)
+	if v3 {
+		block = append(block, u32(v3MinSdk)...)
+		block = append(block, u32(v3MaxSdk)...)
+	}
+	block = append(block, sequence(append(u32(alg), lengthPrefixed(signature)...))...)
+	block = append(block, lengthPrefixed(publicKey)...)
+	return
+}
+
+type blockPair struct {
+	id    uint32
+	value []byte
+}
+
+// signingBlock assembles the APK Signing Block from its id-value pairs
+func signingBlock(pairs ...blockPair) []byte {
+	var body []byte
+	for _, p := range pairs {
+		body = binary.LittleEndian.AppendUint64(body, uint64(len(p.value)+4))
+		body = append(body, u32(p.id)...)
+		body = append(body, p.value...)
+	}
+	size := uint64(len(body) + 8 + len(SigningBlockMagic))
+	block := binary.LittleEndian.AppendUint64(nil, size)
+	block = append(block, body...)
+	block = binary.LittleEndian.AppendUint64(block, size)
+	return append(block, SigningBlockMagic...)
+}
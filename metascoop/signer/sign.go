@@ -0,0 +1,103 @@
+package signer
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+)
+
+// Options selects the signature schemes to apply
+type Options struct {
+	V1 bool
+	V2 bool
+	V3 bool
+}
+
+// Sign returns the APK re-signed with key. Any previous signature is dropped.
+func Sign(apk []byte, key *Key, opts Options) (signed []byte, err error) {
+	if !opts.V1 && !opts.V2 && !opts.V3 {
+		err = errors.New("no signature scheme enabled")
+		return
+	}
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		return
+	}
+
+	var extra map[string][]byte
+	var order []string
+	if opts.V1 {
+		var schemes []string
+		if opts.V2 {
+			schemes = append(schemes, "2")
+		}
+		if opts.V3 {
+			schemes = append(schemes, "3")
+		}
+		if extra, order, err = signV1(r, key, strings.Join(schemes, ", ")); err != nil {
+			return
+		}
+	}
+
+	var unsigned bytes.Buffer
+	if err = rewriteZip(r, &unsigned, extra, order); err != nil {
+		return
+	}
+	if !opts.V2 && !opts.V3 {
+		return unsigned.Bytes(), nil
+	}
+
+	entries, cd, eocd, err := zipSections(unsigned.Bytes())
+	if err != nil {
+		return
+	}
+	digest := contentDigest(crypto.SHA256, entries, cd, eocd)
+
+	var pairs []blockPair
+	if opts.V2 {
+		var attrs [][]byte
+		if opts.V3 {
+			attrs = append(attrs, append(u32(attrStrippingProtection), u32(3)...))
+		}
+		var signer []byte
+		if signer, err = signerBlock(key, digest, false, attrs...); err != nil {
+			return
+		}
+		pairs = append(pairs, blockPair{id: BlockIDV2, value: sequence(signer)})
+	}
+	if opts.V3 {
+		var signer []byte
+		if signer, err = signerBlock(key, digest, true); err != nil {
+			return
+		}
+		pairs = append(pairs, blockPair{id: BlockIDV3, value: sequence(signer)})
+	}
+	block := signingBlock(pairs...)
+
+	newEOCD := bytes.Clone(eocd)
+	binary.LittleEndian.PutUint32(newEOCD[16:], uint32(len(entries)+len(block)))
+
+	signed = make([]byte, 0, len(entries)+len(block)+len(cd)+len(eocd))
+	signed = append(signed, entries...)
+	signed = append(signed, block...)
+	signed = append(signed, cd...)
+	signed = append(signed, newEOCD...)
+	return
+}
+
+// SignFile signs the APK at inPath into outPath
+func SignFile(inPath, outPath string, key *Key, opts Options) (err error) {
+	apk, err := os.ReadFile(inPath)
+	if err != nil {
+		return
+	}
+	signed, err := Sign(apk, key, opts)
+	if err != nil {
+		return
+	}
+	return os.WriteFile(outPath, signed, 0o644)
+}
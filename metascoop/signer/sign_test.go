@@ -0,0 +1,161 @@
+package signer
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// readZip returns the content of the entries of apk, checking the alignment of the
+// uncompressed ones when aligned
+func readZip(t *testing.T, apk []byte, aligned bool) map[string][]byte {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(apk), int64(len(apk)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], err = io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !aligned || f.Method != zip.Store {
+			continue
+		}
+		offset, err := f.DataOffset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		alignment := int64(4)
+		if strings.HasSuffix(f.Name, ".so") {
+			alignment = 4096
+		}
+		if offset%alignment != 0 {
+			t.Errorf("%s: data at %d isn't aligned on %d bytes", f.Name, offset, alignment)
+		}
+	}
+	return files
+}
+
+// verifyV1 checks the JAR signature of files
+func verifyV1(t *testing.T, files map[string][]byte, key *Key) {
+	t.Helper()
+	manifest, sf, block := string(files["META-INF/MANIFEST.MF"]), files["META-INF/CERT.SF"], files["META-INF/CERT.RSA"]
+	if manifest == "" || sf == nil || block == nil {
+		t.Fatal("missing JAR signature files")
+	}
+	for name, data := range files {
+		if isSignatureFile(name) {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		section := "Name: " + name + "\r\nSHA-256-Digest: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n"
+		if !strings.Contains(manifest, section) {
+			t.Errorf("manifest has no digest of %s", name)
+		}
+	}
+	if !bytes.Contains(sf, []byte("SHA-256-Digest-Manifest: "+digest([]byte(manifest))+"\r\n")) {
+		t.Error("signature file doesn't match the manifest")
+	}
+	if !bytes.Contains(sf, []byte("X-Android-APK-Signed: 2, 3\r\n")) {
+		t.Error("signature file doesn't protect the v2 and v3 signatures")
+	}
+
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(block, &ci); err != nil {
+		t.Fatal(err)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		t.Fatal(err)
+	}
+	if len(sd.SignerInfos) != 1 {
+		t.Fatalf("got %d signers", len(sd.SignerInfos))
+	}
+	sum := sha256.Sum256(sf)
+	if err := rsa.VerifyPKCS1v15(key.Certificate.PublicKey.(*rsa.PublicKey), crypto.SHA256, sum[:], sd.SignerInfos[0].EncryptedDigest); err != nil {
+		t.Errorf("verifying the signature file: %v", err)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	key, err := LoadPKCS12("testdata/keystore.p12", "repokey", "fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apk, err := os.ReadFile("testdata/app.apk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := readZip(t, apk, false)
+
+	signed, err := Sign(apk, key, Options{V1: true, V2: true, V3: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Signing again replaces the signatures
+	if signed, err = Sign(signed, key, Options{V1: true, V2: true, V3: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	files := readZip(t, signed, true)
+	for name, data := range original {
+		if !bytes.Equal(files[name], data) {
+			t.Errorf("%s changed", name)
+		}
+	}
+	verifyV1(t, files, key)
+
+	block, err := FindSigningBlock(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := block.Pairs[BlockIDV2]; !ok {
+		t.Error("no v2 signature")
+	}
+	for _, scheme := range []string{"v3", "v2"} {
+		certs, err := block.Verify()
+		if err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+		if len(certs) != 1 || Fingerprint(certs[0]) != Fingerprint(key.Certificate) {
+			t.Errorf("%s: got the wrong signer", scheme)
+		}
+		// Verify checks v2 once there is no v3
+		delete(block.Pairs, BlockIDV3)
+	}
+
+	// Any change to the signed content is detected
+	tampered := bytes.Clone(signed)
+	tampered[100] ^= 0xff
+	if block, err = FindSigningBlock(tampered); err == nil {
+		_, err = block.Verify()
+	}
+	if err == nil {
+		t.Error("the tampered APK verified")
+	}
+}
+
+func TestFindSigningBlockUnsigned(t *testing.T) {
+	apk, err := os.ReadFile("testdata/app.apk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = FindSigningBlock(apk); err != ErrNotSigned {
+		t.Errorf("got %v, want ErrNotSigned", err)
+	}
+}
@@ -0,0 +1,178 @@
+package signer
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+const (
+	createdBy   = "metascoop"
+	maxLineSize = 72
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSASHA256   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+}
+
+// writeAttribute writes a manifest line, wrapping it at 72 bytes
+func writeAttribute(b *bytes.Buffer, name, value string) {
+	line := []byte(name + ": " + value)
+	for first := true; len(line) > 0; first = false {
+		n := maxLineSize
+		if !first {
+			b.WriteByte(' ')
+			n--
+		}
+		n = min(n, len(line))
+		b.Write(line[:n])
+		b.WriteString("\r\n")
+		line = line[n:]
+	}
+}
+
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// signV1 returns the JAR signature files of the entries of r
+func signV1(r *zip.Reader, key *Key, schemes string) (files map[string][]byte, order []string, err error) {
+	var manifest, sf bytes.Buffer
+	writeAttribute(&manifest, "Manifest-Version", "1.0")
+	writeAttribute(&manifest, "Created-By", createdBy)
+	manifest.WriteString("\r\n")
+
+	var sections bytes.Buffer
+	for _, f := range r.File {
+		if isSignatureFile(f.Name) || f.FileInfo().IsDir() {
+			continue
+		}
+		var rc io.ReadCloser
+		if rc, err = f.Open(); err != nil {
+			return
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, rc)
+		_ = rc.Close()
+		if err != nil {
+			return
+		}
+
+		var section bytes.Buffer
+		writeAttribute(&section, "Name", f.Name)
+		writeAttribute(&section, "SHA-256-Digest", base64.StdEncoding.EncodeToString(h.Sum(nil)))
+		section.WriteString("\r\n")
+		manifest.Write(section.Bytes())
+
+		writeAttribute(&sections, "Name", f.Name)
+		writeAttribute(&sections, "SHA-256-Digest", digest(section.Bytes()))
+		sections.WriteString("\r\n")
+	}
+
+	writeAttribute(&sf, "Signature-Version", "1.0")
+	writeAttribute(&sf, "Created-By", createdBy)
+	writeAttribute(&sf, "SHA-256-Digest-Manifest", digest(manifest.Bytes()))
+	if schemes != "" {
+		// Protects the v2/v3 signatures from being stripped
+		writeAttribute(&sf, "X-Android-APK-Signed", schemes)
+	}
+	sf.WriteString("\r\n")
+	sf.Write(sections.Bytes())
+
+	block, ext, err := pkcs7Sign(sf.Bytes(), key)
+	if err != nil {
+		return
+	}
+
+	files = map[string][]byte{
+		"META-INF/MANIFEST.MF": manifest.Bytes(),
+		"META-INF/CERT.SF":     sf.Bytes(),
+		"META-INF/CERT." + ext: block,
+	}
+	order = []string{"META-INF/MANIFEST.MF", "META-INF/CERT.SF", "META-INF/CERT." + ext}
+	return
+}
+
+// pkcs7Sign returns a detached PKCS#7 SignedData of content
+func pkcs7Sign(content []byte, key *Key) (der []byte, ext string, err error) {
+	sum := sha256.Sum256(content)
+	sig, err := key.PrivateKey.Sign(rand.Reader, sum[:], crypto.SHA256)
+	if err != nil {
+		return
+	}
+
+	sha256Alg := pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+	encAlg := pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}
+	ext = "RSA"
+	if _, ok := key.PrivateKey.(*ecdsa.PrivateKey); ok {
+		encAlg = pkix.AlgorithmIdentifier{Algorithm: oidECDSASHA256}
+		ext = "EC"
+	}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Alg},
+		ContentInfo:      contentInfo{ContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: key.Certificate.Raw},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			IssuerAndSerialNumber: issuerAndSerial{
+				Issuer:       asn1.RawValue{FullBytes: key.Certificate.RawIssuer},
+				SerialNumber: key.Certificate.SerialNumber,
+			},
+			DigestAlgorithm:           sha256Alg,
+			DigestEncryptionAlgorithm: encAlg,
+			EncryptedDigest:           sig,
+		}},
+	}
+	inner, err := asn1.Marshal(sd)
+	if err != nil {
+		err = fmt.Errorf("encoding signed data: %w", err)
+		return
+	}
+	der, err = asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: inner},
+	})
+	return
+}
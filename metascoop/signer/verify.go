@@ -0,0 +1,284 @@
+package signer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+var ErrNotSigned = errors.New("APK has no v2/v3 signature")
+
+// SigningBlock is the APK Signing Block of an APK with the sections it protects
+type SigningBlock struct {
+	Pairs   map[uint32][]byte
+	entries []byte
+	cd      []byte
+	eocd    []byte
+}
+
+func readLengthPrefixed(b []byte) (item, rest []byte, err error) {
+	if len(b) < 4 {
+		err = errors.New("truncated length-prefixed field")
+		return
+	}
+	n := binary.LittleEndian.Uint32(b)
+	if uint64(n) > uint64(len(b)-4) {
+		err = errors.New("length-prefixed field out of bounds")
+		return
+	}
+	return b[4 : 4+n], b[4+n:], nil
+}
+
+func readSequence(b []byte) (items [][]byte, err error) {
+	for len(b) > 0 {
+		var item []byte
+		if item, b, err = readLengthPrefixed(b); err != nil {
+			return
+		}
+		items = append(items, item)
+	}
+	return
+}
+
+// FindSigningBlock locates the APK Signing Block of a signed APK
+func FindSigningBlock(apk []byte) (block *SigningBlock, err error) {
+	_, _, eocd, err := zipSections(apk)
+	if err != nil {
+		return
+	}
+	eocdOffset := len(apk) - len(eocd)
+	cdOffset := int(binary.LittleEndian.Uint32(eocd[16:]))
+	if cdOffset < 32 || cdOffset > eocdOffset || string(apk[cdOffset-16:cdOffset]) != SigningBlockMagic {
+		return nil, ErrNotSigned
+	}
+	size := binary.LittleEndian.Uint64(apk[cdOffset-24:])
+	if size < 24 || size > uint64(cdOffset-8) {
+		return nil, errors.New("invalid APK signing block size")
+	}
+	start := cdOffset - int(size) - 8
+	if binary.LittleEndian.Uint64(apk[start:]) != size {
+		return nil, errors.New("APK signing block sizes don't match")
+	}
+
+	block = &SigningBlock{
+		Pairs:   make(map[uint32][]byte),
+		entries: apk[:start],
+		cd:      apk[cdOffset:eocdOffset],
+		eocd:    bytes.Clone(eocd),
+	}
+	// The digest is computed as if the central directory followed the entries
+	binary.LittleEndian.PutUint32(block.eocd[16:], uint32(start))
+
+	pairs := apk[start+8 : cdOffset-24]
+	for len(pairs) > 0 {
+		if len(pairs) < 12 {
+			return nil, errors.New("truncated APK signing block pair")
+		}
+		n := binary.LittleEndian.Uint64(pairs)
+		if n < 4 || n > uint64(len(pairs)-8) {
+			return nil, errors.New("APK signing block pair out of bounds")
+		}
+		block.Pairs[binary.LittleEndian.Uint32(pairs[8:])] = pairs[12 : 8+n]
+		pairs = pairs[8+n:]
+	}
+	return
+}
+
+// Verify checks the v3 signature, or the v2 one when there is no v3, and returns the signer certificates
+func (b *SigningBlock) Verify() (certs []*x509.Certificate, err error) {
+	value, v3 := b.Pairs[BlockIDV3]
+	if !v3 {
+		var ok bool
+		if value, ok = b.Pairs[BlockIDV2]; !ok {
+			return nil, ErrNotSigned
+		}
+	}
+
+	seq, rest, err := readLengthPrefixed(value)
+	if err != nil {
+		return
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing data after signers")
+	}
+	signers, err := readSequence(seq)
+	if err != nil {
+		return
+	}
+	if len(signers) == 0 {
+		return nil, errors.New("no signers")
+	}
+
+	digests := make(map[crypto.Hash][]byte)
+	for _, signer := range signers {
+		var cert *x509.Certificate
+		if cert, err = b.verifySigner(signer, v3, digests); err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return
+}
+
+// sigAlgorithms are the supported signature algorithms, the strongest one of a signer is verified
+var sigAlgorithms = []uint32{sigRSAPSSSHA512, sigRSAPKCS1SHA512, sigECDSASHA512, sigRSAPSSSHA256, sigRSAPKCS1SHA256, sigECDSASHA256}
+
+// algorithmHash returns the hash of the signed data and the content digest of a signature algorithm
+func algorithmHash(alg uint32) crypto.Hash {
+	switch alg {
+	case sigRSAPSSSHA512, sigRSAPKCS1SHA512, sigECDSASHA512:
+		return crypto.SHA512
+	}
+	return crypto.SHA256
+}
+
+func verifySignature(publicKey any, alg uint32, signedData, sig []byte) error {
+	hash := algorithmHash(alg)
+	h := hash.New()
+	h.Write(signedData)
+	sum := h.Sum(nil)
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case sigRSAPSSSHA256, sigRSAPSSSHA512:
+			return rsa.VerifyPSS(k, hash, sum, sig, &rsa.PSSOptions{SaltLength: hash.Size()})
+		case sigRSAPKCS1SHA256, sigRSAPKCS1SHA512:
+			return rsa.VerifyPKCS1v15(k, hash, sum, sig)
+		}
+	case *ecdsa.PublicKey:
+		if alg == sigECDSASHA256 || alg == sigECDSASHA512 {
+			if !ecdsa.VerifyASN1(k, sum, sig) {
+				return errors.New("invalid ECDSA signature")
+			}
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported public key %T", publicKey)
+	}
+	return fmt.Errorf("signature algorithm %#04x doesn't match the %T public key", alg, publicKey)
+}
+
+func (b *SigningBlock) verifySigner(signer []byte, v3 bool, contentDigests map[crypto.Hash][]byte) (cert *x509.Certificate, err error) {
+	signedData, rest, err := readLengthPrefixed(signer)
+	if err != nil {
+		return
+	}
+	if v3 {
+		if len(rest) < 8 {
+			return nil, errors.New("truncated v3 signer")
+		}
+		rest = rest[8:]
+	}
+	signaturesSeq, rest, err := readLengthPrefixed(rest)
+	if err != nil {
+		return
+	}
+	publicKeyDER, _, err := readLengthPrefixed(rest)
+	if err != nil {
+		return
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(publicKeyDER)
+	if err != nil {
+		return
+	}
+
+	signatures, err := readSequence(signaturesSeq)
+	if err != nil {
+		return
+	}
+	sigs := make(map[uint32][]byte)
+	for _, s := range signatures {
+		if len(s) < 4 {
+			continue
+		}
+		if sigs[binary.LittleEndian.Uint32(s)], _, err = readLengthPrefixed(s[4:]); err != nil {
+			return
+		}
+	}
+	i := slices.IndexFunc(sigAlgorithms, func(a uint32) bool { _, ok := sigs[a]; return ok })
+	if i < 0 {
+		return nil, errors.New("no supported signature algorithm")
+	}
+	alg := sigAlgorithms[i]
+	if err = verifySignature(publicKey, alg, signedData, sigs[alg]); err != nil {
+		return
+	}
+
+	digestsSeq, rest, err := readLengthPrefixed(signedData)
+	if err != nil {
+		return
+	}
+	certsSeq, _, err := readLengthPrefixed(rest)
+	if err != nil {
+		return
+	}
+	digests, err := readSequence(digestsSeq)
+	if err != nil {
+		return
+	}
+	var expected []byte
+	for _, d := range digests {
+		if len(d) >= 4 && binary.LittleEndian.Uint32(d) == alg {
+			if expected, _, err = readLengthPrefixed(d[4:]); err != nil {
+				return
+			}
+		}
+	}
+	if expected == nil {
+		return nil, errors.New("no digest for the signature algorithm")
+	}
+	hash := algorithmHash(alg)
+	if contentDigests[hash] == nil {
+		contentDigests[hash] = contentDigest(hash, b.entries, b.cd, b.eocd)
+	}
+	if !bytes.Equal(expected, contentDigests[hash]) {
+		return nil, errors.New("APK content digest mismatch")
+	}
+
+	rawCerts, err := readSequence(certsSeq)
+	if err != nil {
+		return
+	}
+	if len(rawCerts) == 0 {
+		return nil, errors.New("signer has no certificate")
+	}
+	if cert, err = x509.ParseCertificate(rawCerts[0]); err != nil {
+		return
+	}
+	certKey, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return
+	}
+	if !bytes.Equal(certKey, publicKeyDER) {
+		return nil, errors.New("certificate doesn't match the signer public key")
+	}
+	return
+}
+
+// VerifyFile checks the signature of the APK at path and returns its signer certificates
+func VerifyFile(path string) (certs []*x509.Certificate, err error) {
+	apk, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	block, err := FindSigningBlock(apk)
+	if err != nil {
+		return
+	}
+	return block.Verify()
+}
+
+// Fingerprint returns the lowercase hex SHA-256 of the certificate, as used by F-Droid
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
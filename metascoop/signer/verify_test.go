@@ -0,0 +1,44 @@
+package signer
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"os"
+	"testing"
+)
+
+// testdata/app-sha512.apk is signed with a 4096-bit RSA key, v2 with RSA PKCS#1 SHA-512 (0x0104)
+// and v3 with RSA-PSS SHA-512 (0x0102), both over the CHUNKED_SHA512 content digest
+func TestVerifySHA512(t *testing.T) {
+	apk, err := os.ReadFile("testdata/app-sha512.apk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := FindSigningBlock(apk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, scheme := range []string{"v3", "v2"} {
+		certs, err := block.Verify()
+		if err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+		if len(certs) != 1 || certs[0].Subject.CommonName != "sha512" {
+			t.Fatalf("%s: got the wrong signer", scheme)
+		}
+		if k, ok := certs[0].PublicKey.(*rsa.PublicKey); !ok || k.N.BitLen() != 4096 {
+			t.Errorf("%s: got a %T public key, want a 4096-bit RSA one", scheme, certs[0].PublicKey)
+		}
+		// Verify checks v2 once there is no v3
+		delete(block.Pairs, BlockIDV3)
+	}
+
+	tampered := bytes.Clone(apk)
+	tampered[100] ^= 0xff
+	if block, err = FindSigningBlock(tampered); err == nil {
+		_, err = block.Verify()
+	}
+	if err == nil {
+		t.Error("the tampered APK verified")
+	}
+}
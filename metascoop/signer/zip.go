@@ -0,0 +1,150 @@
+package signer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	alignmentExtraID = 0xd935
+	localHeaderSize  = 30
+	eocdSize         = 22
+	eocdSignature    = 0x06054b50
+)
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n += int64(n)
+	return
+}
+
+// isSignatureFile reports whether name is part of a JAR signature
+func isSignatureFile(name string) bool {
+	if path.Dir(name) != "META-INF" {
+		return false
+	}
+	upper := strings.ToUpper(path.Base(name))
+	if upper == "MANIFEST.MF" {
+		return true
+	}
+	switch path.Ext(upper) {
+	case ".SF", ".RSA", ".DSA", ".EC":
+		return true
+	}
+	return false
+}
+
+// rewriteZip copies every entry but the JAR signature files into out, aligning
+// uncompressed entries the way zipalign does. extra entries are appended at the end.
+func rewriteZip(r *zip.Reader, out io.Writer, extra map[string][]byte, extraOrder []string) (err error) {
+	cw := &countingWriter{w: out}
+	w := zip.NewWriter(cw)
+
+	for _, f := range r.File {
+		if isSignatureFile(f.Name) {
+			continue
+		}
+		if err = w.Flush(); err != nil {
+			return
+		}
+
+		fh := f.FileHeader
+		// Sizes are known, no need for a data descriptor
+		fh.Flags &^= 0x8
+		fh.Extra = stripAlignment(fh.Extra)
+		if fh.Method == zip.Store {
+			alignment := int64(4)
+			if strings.HasSuffix(f.Name, ".so") {
+				alignment = 4096
+			}
+			fh.Extra = append(fh.Extra, alignmentPadding(cw.n+localHeaderSize+int64(len(fh.Name)+len(fh.Extra)), alignment)...)
+		}
+
+		var rc io.Reader
+		if rc, err = f.OpenRaw(); err != nil {
+			return
+		}
+		var fw io.Writer
+		if fw, err = w.CreateRaw(&fh); err != nil {
+			return
+		}
+		if _, err = io.Copy(fw, rc); err != nil {
+			return
+		}
+	}
+
+	for _, name := range extraOrder {
+		var fw io.Writer
+		if fw, err = w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate}); err != nil {
+			return
+		}
+		if _, err = fw.Write(extra[name]); err != nil {
+			return
+		}
+	}
+
+	return w.Close()
+}
+
+func stripAlignment(extra []byte) []byte {
+	var out []byte
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if 4+size > len(extra) {
+			break
+		}
+		if id != alignmentExtraID {
+			out = append(out, extra[:4+size]...)
+		}
+		extra = extra[4+size:]
+	}
+	return out
+}
+
+// alignmentPadding returns an extra field moving data starting at offset to the next multiple of alignment
+func alignmentPadding(offset int64, alignment int64) []byte {
+	// The field needs at least its header and the alignment value
+	pad := (alignment - (offset+6)%alignment) % alignment
+	field := make([]byte, 6+pad)
+	binary.LittleEndian.PutUint16(field, alignmentExtraID)
+	binary.LittleEndian.PutUint16(field[2:], uint16(2+pad))
+	binary.LittleEndian.PutUint16(field[4:], uint16(alignment))
+	return field
+}
+
+// zipSections splits an unsigned zip into its entries, central directory and end of central directory
+func zipSections(data []byte) (entries, cd, eocd []byte, err error) {
+	start := len(data) - eocdSize - 0xffff
+	if start < 0 {
+		start = 0
+	}
+	sig := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sig, eocdSignature)
+	pos := bytes.LastIndex(data[start:], sig)
+	if pos < 0 || start+pos+eocdSize > len(data) {
+		err = errors.New("end of central directory not found")
+		return
+	}
+	pos += start
+	eocd = data[pos:]
+	cdSize := int64(binary.LittleEndian.Uint32(eocd[12:]))
+	cdOffset := int64(binary.LittleEndian.Uint32(eocd[16:]))
+	if cdOffset == 0xffffffff || cdOffset+cdSize != int64(pos) {
+		err = errors.New("unsupported zip64 or malformed central directory")
+		return
+	}
+	entries = data[:cdOffset]
+	cd = data[cdOffset:pos]
+	return
+}